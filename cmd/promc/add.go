@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/common/model"
+	"github.com/remiges-tech/serversage/promc"
+	"github.com/spf13/cobra"
+)
+
+// stateRegexp matches the valid states of a stateset metric.
+var stateRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// prompter asks questions on standard input and output.
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// ask prints question and returns the trimmed answer, or defaultValue if the
+// answer is empty. It asks again until validate accepts the answer.
+func (p *prompter) ask(question, defaultValue string, validate func(string) error) (string, error) {
+	for {
+		if defaultValue != "" {
+			fmt.Fprintf(p.out, "%s [%s]: ", question, defaultValue)
+		} else {
+			fmt.Fprintf(p.out, "%s: ", question)
+		}
+		line, err := p.in.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", err
+		}
+		answer := strings.TrimSpace(line)
+		if answer == "" {
+			answer = defaultValue
+		}
+		if validate != nil {
+			if err := validate(answer); err != nil {
+				fmt.Fprintf(p.out, "  %v\n", err)
+				continue
+			}
+		}
+		return answer, nil
+	}
+}
+
+func newAddCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "add CONFIG",
+		Short: "Interactively adds a metric to a configuration file",
+		Long: `Asks for the name, type, help text, unit, labels and buckets of a new metric,
+checks it like promc lint --strict and adds it to the configuration file, which
+is rewritten in the canonical form of promc fmt.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			path := args[0]
+			p := &prompter{in: bufio.NewReader(os.Stdin), out: os.Stdout}
+
+			err := addMetric(p, path)
+			if err == io.EOF {
+				fmt.Println("\naborted")
+				os.Exit(1)
+			}
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	return cmd
+}
+
+// addMetric asks for the definition of a new metric and adds it to the
+// configuration file at path.
+func addMetric(p *prompter, path string) error {
+	config, err := loadConfig(path, false)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %v", err)
+	}
+	document, err := decodeConfigDocument(content, isYAMLPath(path))
+	if err != nil {
+		return err
+	}
+
+	metric, err := askMetric(p, config)
+	if err != nil {
+		return err
+	}
+
+	// Check the metric the way lint does, reporting only the problems it
+	// introduces.
+	var parsed promc.Metric
+	encoded, err := json.Marshal(metric)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(encoded, &parsed); err != nil {
+		return err
+	}
+	parsed.ConfiguredName = parsed.Name
+	parsed.Name = promc.SuffixedName(parsed)
+	parsed.Namespace = config.Namespace
+	parsed.Source = path
+	extended := *config
+	extended.Metrics = append(append([]promc.Metric(nil), config.Metrics...), parsed)
+
+	oldCollisions := collisionProblems(config)
+	var problems []string
+	for _, problem := range collisionProblems(&extended) {
+		if !contains(oldCollisions, problem) {
+			problems = append(problems, problem)
+		}
+	}
+	problems = append(problems, strictProblems(&promc.MetricConfig{Metrics: []promc.Metric{parsed}})...)
+	if len(problems) > 0 {
+		fmt.Fprintf(p.out, "the metric has problems:\n- %s\n", strings.Join(problems, "\n- "))
+		answer, err := p.ask("Add it anyway? (y/n)", "n", oneOf("y", "n"))
+		if err != nil {
+			return err
+		}
+		if answer != "y" {
+			return fmt.Errorf("metric not added")
+		}
+	}
+
+	metrics, _ := document["metrics"].([]interface{})
+	document["metrics"] = append(metrics, metric)
+	encoded, err = json.Marshal(document)
+	if err != nil {
+		return err
+	}
+	if err := validateConfig(encoded); err != nil {
+		return fmt.Errorf("config validation failed: %v", err)
+	}
+	formatted, err := encodeConfigDocument(document, isYAMLPath(path))
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(path, formatted, 0o644)
+	if err != nil {
+		return fmt.Errorf("error writing config file: %v", err)
+	}
+	fmt.Fprintf(p.out, "added %s to %s\n", metric["name"], path)
+	return nil
+}
+
+// askMetric asks for the fields of a metric that is not yet in config and
+// returns it as it is written in a configuration file.
+func askMetric(p *prompter, config *promc.MetricConfig) (map[string]interface{}, error) {
+	metric := make(map[string]interface{})
+
+	name, err := p.ask("Name", "", func(name string) error {
+		if !model.IsValidMetricName(model.LabelValue(name)) {
+			return fmt.Errorf("%q is not a valid metric name", name)
+		}
+		for _, existing := range config.Metrics {
+			if existing.Name == name || existing.ConfiguredName == name {
+				return fmt.Errorf("%s is already defined in %s", name, existing.Source)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	metric["name"] = name
+
+	defaultType := "gauge"
+	if strings.HasSuffix(name, "_total") {
+		defaultType = "counter"
+	}
+	metricType, err := p.ask("Type (counter, gauge, histogram, summary, info, stateset)", defaultType, oneOf("counter", "gauge", "histogram", "summary", "info", "stateset"))
+	if err != nil {
+		return nil, err
+	}
+	metric["type"] = metricType
+
+	help, err := p.ask("Help text", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	if help != "" {
+		metric["help"] = help
+	}
+
+	if metricType != "info" && metricType != "stateset" {
+		defaultUnit := "-"
+		for _, unit := range promc.Units {
+			if strings.HasSuffix(strings.TrimSuffix(name, "_total"), "_"+unit) {
+				defaultUnit = unit
+			}
+		}
+		unit, err := p.ask("Unit ("+strings.Join(promc.Units, ", ")+", - for none)", defaultUnit, oneOf(append([]string{"-"}, promc.Units...)...))
+		if err != nil {
+			return nil, err
+		}
+		if unit != "-" {
+			metric["unit"] = unit
+		}
+	}
+
+	labels, err := p.ask("Labels (comma separated)", "", func(answer string) error {
+		seen := make(map[string]bool)
+		for _, label := range splitList(answer) {
+			if !model.LabelName(label).IsValid() {
+				return fmt.Errorf("%q is not a valid label name", label)
+			}
+			if seen[label] {
+				return fmt.Errorf("label %s is listed twice", label)
+			}
+			seen[label] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if names := splitList(labels); len(names) > 0 {
+		metric["labels"] = names
+	}
+
+	if metricType == "stateset" {
+		states, err := p.ask("States (comma separated)", "", func(answer string) error {
+			states := splitList(answer)
+			if len(states) == 0 {
+				return fmt.Errorf("at least one state is required")
+			}
+			seen := make(map[string]bool)
+			for _, state := range states {
+				if !stateRegexp.MatchString(state) {
+					return fmt.Errorf("%q is not a valid state, use letters, digits and underscores", state)
+				}
+				if seen[state] {
+					return fmt.Errorf("state %s is listed twice", state)
+				}
+				seen[state] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		metric["states"] = splitList(states)
+	}
+
+	if metricType == "histogram" {
+		defaultBuckets := "default"
+		switch metric["unit"] {
+		case "seconds":
+			defaultBuckets = "latency_slow"
+		case "bytes":
+			defaultBuckets = "sizes"
+		}
+		fmt.Fprintf(p.out, "Bucket presets: %s\n", strings.Join(promc.BucketPresetNames(), ", "))
+		answer, err := p.ask("Buckets (preset, linear(start, width, count), exponential(start, factor, count) or a comma separated list)", defaultBuckets, func(answer string) error {
+			_, err := parseBucketsAnswer(answer)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		metric["buckets"], _ = parseBucketsAnswer(answer)
+	}
+
+	return metric, nil
+}
+
+// parseBucketsAnswer returns the buckets value written to the configuration
+// for an answer to the buckets question.
+func parseBucketsAnswer(answer string) (interface{}, error) {
+	if _, err := promc.ExpandBuckets(answer); err == nil {
+		return promc.NormalizeBucketExpr(answer), nil
+	}
+
+	var values []interface{}
+	previous := 0.0
+	for i, field := range splitList(answer) {
+		value, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			_, err := promc.ExpandBuckets(answer)
+			return nil, err
+		}
+		if i > 0 && value <= previous {
+			return nil, fmt.Errorf("buckets must be in increasing order")
+		}
+		values = append(values, value)
+		previous = value
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("at least one bucket is required")
+	}
+	return values, nil
+}
+
+// oneOf returns a validation function accepting only the given answers.
+func oneOf(answers ...string) func(string) error {
+	return func(answer string) error {
+		if !contains(answers, answer) {
+			return fmt.Errorf("must be one of %s", strings.Join(answers, ", "))
+		}
+		return nil
+	}
+}
+
+// splitList splits a comma separated answer into its trimmed, non-empty
+// elements.
+func splitList(answer string) []string {
+	var elements []string
+	for _, element := range strings.Split(answer, ",") {
+		if element = strings.TrimSpace(element); element != "" {
+			elements = append(elements, element)
+		}
+	}
+	return elements
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/remiges-tech/serversage/promc"
+)
+
+// scriptedPrompter returns a prompter reading the given answers, one per
+// line, and the buffer it writes to.
+func scriptedPrompter(answers ...string) (*prompter, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	in := strings.NewReader(strings.Join(answers, "\n") + "\n")
+	return &prompter{in: bufio.NewReader(in), out: out}, out
+}
+
+func TestAskMetric(t *testing.T) {
+	config := &promc.MetricConfig{Metrics: []promc.Metric{
+		{Name: "shop_orders_total", ConfiguredName: "orders_total", Source: "metrics.json"},
+	}}
+
+	tests := []struct {
+		name     string
+		answers  []string
+		want     map[string]interface{}
+		rejected []string
+	}{
+		{
+			name:    "counter with defaults",
+			answers: []string{"payments_total", "", "Payments made.", "", "method, status"},
+			want: map[string]interface{}{
+				"name":   "payments_total",
+				"type":   "counter",
+				"help":   "Payments made.",
+				"labels": []string{"method", "status"},
+			},
+		},
+		{
+			name:    "histogram with default buckets",
+			answers: []string{"latency_seconds", "histogram", "", "", "", ""},
+			want: map[string]interface{}{
+				"name":    "latency_seconds",
+				"type":    "histogram",
+				"unit":    "seconds",
+				"buckets": "latency_slow",
+			},
+		},
+		{
+			name:    "histogram with bucket list",
+			answers: []string{"size_bytes", "histogram", "", "", "", "100, 1000"},
+			want: map[string]interface{}{
+				"name":    "size_bytes",
+				"type":    "histogram",
+				"unit":    "bytes",
+				"buckets": []interface{}{100.0, 1000.0},
+			},
+		},
+		{
+			name: "invalid answers asked again",
+			answers: []string{
+				"bad-name", "orders_total", "shop_orders_total", "queue_length",
+				"meter", "gauge", "",
+				"inches", "",
+				"queue, bad-label", "queue, queue", "queue",
+			},
+			want: map[string]interface{}{
+				"name":   "queue_length",
+				"type":   "gauge",
+				"labels": []string{"queue"},
+			},
+			rejected: []string{
+				`"bad-name" is not a valid metric name`,
+				"orders_total is already defined in metrics.json",
+				"shop_orders_total is already defined in metrics.json",
+				"must be one of counter, gauge, histogram, summary, info, stateset",
+				"must be one of -, ",
+				`"bad-label" is not a valid label name`,
+				"label queue is listed twice",
+			},
+		},
+		{
+			name:    "stateset",
+			answers: []string{"circuit_state", "stateset", "", "", "", "open, half-open", "open, open", "open, closed"},
+			want: map[string]interface{}{
+				"name":   "circuit_state",
+				"type":   "stateset",
+				"states": []string{"open", "closed"},
+			},
+			rejected: []string{
+				"at least one state is required",
+				`"half-open" is not a valid state, use letters, digits and underscores`,
+				"state open is listed twice",
+			},
+		},
+		{
+			name:    "invalid buckets",
+			answers: []string{"latency_seconds", "histogram", "", "", "", "1, 0.5", "0.5, 1"},
+			want: map[string]interface{}{
+				"name":    "latency_seconds",
+				"type":    "histogram",
+				"unit":    "seconds",
+				"buckets": []interface{}{0.5, 1.0},
+			},
+			rejected: []string{"buckets must be in increasing order"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, out := scriptedPrompter(tt.answers...)
+			got, err := askMetric(p, config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("askMetric() = %#v, want %#v", got, tt.want)
+			}
+			for _, message := range tt.rejected {
+				if !strings.Contains(out.String(), "  "+message) {
+					t.Errorf("output does not reject with %q:\n%s", message, out)
+				}
+			}
+		})
+	}
+}
+
+func TestAskMetricEOF(t *testing.T) {
+	p, _ := scriptedPrompter("orders_total", "counter")
+	if _, err := askMetric(p, &promc.MetricConfig{}); err != io.EOF {
+		t.Errorf("askMetric() error = %v, want %v", err, io.EOF)
+	}
+}
+
+func TestAddMetric(t *testing.T) {
+	original := `{"namespace": "shop", "metrics": [{"name": "orders_total", "type": "counter", "help": "Orders placed."}]}`
+
+	tests := []struct {
+		name         string
+		answers      []string
+		want         []string
+		wantProblems []string
+		wantErr      string
+	}{
+		{
+			name:    "added",
+			answers: []string{"queue_length", "", "Queued orders.", "", ""},
+			want:    []string{"orders_total", "queue_length"},
+		},
+		{
+			name:         "problems accepted",
+			answers:      []string{"payments", "counter", "", "", "", "y"},
+			want:         []string{"orders_total", "payments"},
+			wantProblems: []string{"payments: help text is missing", "payments: counter name must end in _total"},
+		},
+		{
+			name:         "problems declined",
+			answers:      []string{"payments", "counter", "", "", "", ""},
+			want:         []string{"orders_total"},
+			wantProblems: []string{"payments: help text is missing", "payments: counter name must end in _total"},
+			wantErr:      "metric not added",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "metrics.json")
+			if err := os.WriteFile(path, []byte(original), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			p, out := scriptedPrompter(tt.answers...)
+			err := addMetric(p, path)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("addMetric() error = %v, want %s", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			for _, problem := range tt.wantProblems {
+				if !strings.Contains(out.String(), "- "+problem+"\n") {
+					t.Errorf("output does not list problem %q:\n%s", problem, out)
+				}
+			}
+
+			config, err := loadConfig(path, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, metric := range config.Metrics {
+				names = append(names, metric.Name)
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("metrics = %q, want %q", names, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/api"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/remiges-tech/serversage/promc"
+	"github.com/spf13/cobra"
+)
+
+// runtimeSeriesPrefixes are the prefixes of series that are exposed next to
+// the configured metrics without being declared, by the client library, by
+// the generated code or by Prometheus itself.
+var runtimeSeriesPrefixes = []string{"go_", "process_", "promhttp_", "promc_", "scrape_"}
+
+// scrapedMetric is the number of series Prometheus holds for a configured
+// metric.
+type scrapedMetric struct {
+	Name   string
+	Series int
+}
+
+func newCheckCmd() *cobra.Command {
+	var configPaths []string
+	var promURL, job string
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Checks that a live Prometheus server scrapes exactly the configured metrics",
+		Long: `Queries a Prometheus server for the series of every configured metric and
+reports how many it holds. Configured metrics without series and scraped
+metrics missing from the configuration are reported as problems, and the
+command exits with a non-zero status if there are any.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			config, err := loadConfigs(configPaths, false)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			client, err := api.NewClient(api.Config{Address: promURL})
+			if err != nil {
+				fmt.Printf("error creating Prometheus client: %v\n", err)
+				os.Exit(1)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			scraped, undeclared, err := checkMetrics(ctx, v1.NewAPI(client), config, job, time.Now())
+			if err != nil {
+				fmt.Printf("error querying Prometheus: %v\n", err)
+				os.Exit(1)
+			}
+
+			problems := 0
+			for _, metric := range scraped {
+				status := "ok"
+				if metric.Series == 0 {
+					status = "MISSING"
+					problems++
+				}
+				fmt.Printf("%-10s  %s: %d series\n", status, metric.Name, metric.Series)
+			}
+			for _, name := range undeclared {
+				fmt.Printf("%-10s  %s: not in the configuration\n", "UNDECLARED", name)
+				problems++
+			}
+			if undeclared == nil && job == "" {
+				fmt.Println("pass --job to also check for metrics missing from the configuration")
+			}
+			if problems > 0 {
+				fmt.Printf("check failed: %d problem(s) found\n", problems)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringArrayVarP(&configPaths, "config", "c", nil, "Path to the configuration file, may be repeated to merge several files (required)")
+	cmd.Flags().StringVar(&promURL, "prom-url", "", "URL of the Prometheus server, e.g. http://prom:9090 (required)")
+	cmd.Flags().StringVar(&job, "job", "", "Only consider series of this scrape job")
+	cmd.Flags().DurationVar(&timeout, "timeout", 30*time.Second, "Timeout of the queries to Prometheus")
+	cmd.MarkFlagRequired("config")
+	cmd.MarkFlagRequired("prom-url")
+
+	return cmd
+}
+
+// checkMetrics returns the number of series Prometheus holds at now for every
+// metric of config, and the sorted names of the scraped metrics that config
+// does not declare. Undeclared metrics are looked up in job, or under the
+// namespaces of config if job is empty; undeclared is nil if neither is set.
+func checkMetrics(ctx context.Context, promAPI v1.API, config *promc.MetricConfig, job string, now time.Time) ([]scrapedMetric, []string, error) {
+	jobMatcher := ""
+	if job != "" {
+		jobMatcher = fmt.Sprintf(",job=%q", job)
+	}
+
+	query := fmt.Sprintf("count by (__name__) ({__name__=~%q%s})", seriesNameRegex(config.Metrics), jobMatcher)
+	result, _, err := promAPI.Query(ctx, query, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	vector, ok := result.(model.Vector)
+	if !ok {
+		return nil, nil, fmt.Errorf("unexpected result type %s for %s", result.Type(), query)
+	}
+
+	scraped := make([]scrapedMetric, len(config.Metrics))
+	patterns := make([]*regexp.Regexp, len(config.Metrics))
+	for i, metric := range config.Metrics {
+		scraped[i].Name = prometheus.BuildFQName(metric.Namespace, "", metric.Name)
+		patterns[i] = regexp.MustCompile("^(?:" + seriesNameRegex([]promc.Metric{metric}) + ")$")
+	}
+	for _, sample := range vector {
+		name := string(sample.Metric[model.MetricNameLabel])
+		for i, pattern := range patterns {
+			if pattern.MatchString(name) {
+				scraped[i].Series += int(sample.Value)
+				break
+			}
+		}
+	}
+
+	var selectors []string
+	if job != "" {
+		selectors = []string{fmt.Sprintf("{job=%q}", job)}
+	} else {
+		namespaces := make(map[string]bool)
+		for _, metric := range config.Metrics {
+			if metric.Namespace != "" && !namespaces[metric.Namespace] {
+				namespaces[metric.Namespace] = true
+				selectors = append(selectors, fmt.Sprintf("{__name__=~%q}", metric.Namespace+"_.+"))
+			}
+		}
+	}
+	if len(selectors) == 0 {
+		return scraped, nil, nil
+	}
+
+	names, _, err := promAPI.LabelValues(ctx, model.MetricNameLabel, selectors, now.Add(-5*time.Minute), now)
+	if err != nil {
+		return nil, nil, err
+	}
+	undeclared := []string{}
+	for _, value := range names {
+		name := string(value)
+		if name == "up" || hasAnyPrefix(name, runtimeSeriesPrefixes) {
+			continue
+		}
+		declared := false
+		for _, pattern := range patterns {
+			if pattern.MatchString(name) {
+				declared = true
+				break
+			}
+		}
+		if !declared {
+			undeclared = append(undeclared, name)
+		}
+	}
+	sort.Strings(undeclared)
+
+	return scraped, undeclared, nil
+}
+
+// hasAnyPrefix reports whether s begins with one of prefixes.
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/api"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/remiges-tech/serversage/promc"
+)
+
+// fakePrometheus serves the query and label values endpoints of the
+// Prometheus HTTP API from fixed series counts and metric names, and records
+// the queries and label matchers it receives.
+type fakePrometheus struct {
+	series map[string]int
+	names  []string
+
+	queries  []string
+	matchers [][]string
+}
+
+func (f *fakePrometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var data interface{}
+	switch r.URL.Path {
+	case "/api/v1/query":
+		f.queries = append(f.queries, r.Form.Get("query"))
+		result := []interface{}{}
+		for name, count := range f.series {
+			result = append(result, map[string]interface{}{
+				"metric": map[string]string{"__name__": name},
+				"value":  []interface{}{1700000000, strconv.Itoa(count)},
+			})
+		}
+		data = map[string]interface{}{"resultType": "vector", "result": result}
+	case "/api/v1/label/__name__/values":
+		f.matchers = append(f.matchers, r.Form["match[]"])
+		data = f.names
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "data": data})
+}
+
+func TestCheckMetrics(t *testing.T) {
+	config := &promc.MetricConfig{Metrics: []promc.Metric{
+		{Name: "orders_total", Type: "counter", Namespace: "shop"},
+		{Name: "checkout_duration_seconds", Type: "histogram", Namespace: "shop"},
+		{Name: "cart_items", Type: "gauge", Namespace: "shop"},
+	}}
+	series := map[string]int{
+		"shop_orders_total":                     3,
+		"shop_checkout_duration_seconds_bucket": 22,
+		"shop_checkout_duration_seconds_sum":    2,
+		"shop_checkout_duration_seconds_count":  2,
+	}
+	names := []string{
+		"go_goroutines",
+		"promc_unexpected_label_values_total",
+		"shop_checkout_duration_seconds_bucket",
+		"shop_legacy_total",
+		"shop_orders_total",
+		"up",
+		"http_requests_total",
+	}
+	wantScraped := []scrapedMetric{
+		{Name: "shop_orders_total", Series: 3},
+		{Name: "shop_checkout_duration_seconds", Series: 26},
+		{Name: "shop_cart_items", Series: 0},
+	}
+
+	tests := []struct {
+		name           string
+		config         *promc.MetricConfig
+		job            string
+		wantQuery      string
+		wantMatchers   [][]string
+		wantUndeclared []string
+	}{
+		{
+			name:           "job",
+			config:         config,
+			job:            "shop",
+			wantQuery:      `count by (__name__) ({__name__=~"shop_cart_items|shop_checkout_duration_seconds(_bucket|_sum|_count)?|shop_orders_total",job="shop"})`,
+			wantMatchers:   [][]string{{`{job="shop"}`}},
+			wantUndeclared: []string{"http_requests_total", "shop_legacy_total"},
+		},
+		{
+			name:           "namespaces",
+			config:         config,
+			wantQuery:      `count by (__name__) ({__name__=~"shop_cart_items|shop_checkout_duration_seconds(_bucket|_sum|_count)?|shop_orders_total"})`,
+			wantMatchers:   [][]string{{`{__name__=~"shop_.+"}`}},
+			wantUndeclared: []string{"http_requests_total", "shop_legacy_total"},
+		},
+		{
+			name: "no job or namespace",
+			config: &promc.MetricConfig{Metrics: []promc.Metric{
+				{Name: "shop_orders_total", Type: "counter"},
+				{Name: "shop_checkout_duration_seconds", Type: "histogram"},
+				{Name: "shop_cart_items", Type: "gauge"},
+			}},
+			wantQuery: `count by (__name__) ({__name__=~"shop_cart_items|shop_checkout_duration_seconds(_bucket|_sum|_count)?|shop_orders_total"})`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakePrometheus{series: series, names: names}
+			server := httptest.NewServer(fake)
+			defer server.Close()
+			client, err := api.NewClient(api.Config{Address: server.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			scraped, undeclared, err := checkMetrics(context.Background(), v1.NewAPI(client), tt.config, tt.job, time.Unix(1700000000, 0))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(scraped, wantScraped) {
+				t.Errorf("scraped = %v, want %v", scraped, wantScraped)
+			}
+			if !reflect.DeepEqual(undeclared, tt.wantUndeclared) {
+				t.Errorf("undeclared = %q, want %q", undeclared, tt.wantUndeclared)
+			}
+			if !reflect.DeepEqual(fake.queries, []string{tt.wantQuery}) {
+				t.Errorf("queries = %q, want %q", fake.queries, []string{tt.wantQuery})
+			}
+			if !reflect.DeepEqual(fake.matchers, tt.wantMatchers) {
+				t.Errorf("label matchers = %q, want %q", fake.matchers, tt.wantMatchers)
+			}
+		})
+	}
+}
+
+func TestCheckMetricsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"status": "error", "errorType": "bad_data", "error": "invalid query"}`))
+	}))
+	defer server.Close()
+	client, err := api.NewClient(api.Config{Address: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &promc.MetricConfig{Metrics: []promc.Metric{{Name: "orders_total", Type: "counter"}}}
+	_, _, err = checkMetrics(context.Background(), v1.NewAPI(client), config, "shop", time.Now())
+	if err == nil || !strings.Contains(err.Error(), "invalid query") {
+		t.Errorf("checkMetrics() error = %v, want the error of the server", err)
+	}
+}
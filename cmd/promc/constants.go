@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/remiges-tech/serversage/promc"
+	"github.com/spf13/cobra"
+)
+
+// constantsTemplates are the templates of the constants files, by language.
+var constantsTemplates = map[string]string{
+	"ts": `// Code generated by promc; DO NOT EDIT.
+{{range .Metrics}}
+{{with .Help}}/** {{doc .}} */
+{{end}}export const {{.Name}} = "{{.Value}}";
+{{- end}}
+{{- if .Labels}}
+{{range .Labels}}
+export const {{.Name}} = "{{.Value}}";
+{{- end}}
+{{- end}}
+`,
+	"java": `// Code generated by promc; DO NOT EDIT.
+{{with .Package}}
+package {{.}};
+{{end}}
+public final class {{.Class}} {
+    private {{.Class}}() {}
+{{range .Metrics}}
+{{with .Help}}    /** {{doc .}} */
+{{end}}    public static final String {{.Name}} = "{{.Value}}";
+{{- end}}
+{{- if .Labels}}
+{{range .Labels}}
+    public static final String {{.Name}} = "{{.Value}}";
+{{- end}}
+{{- end}}
+}
+`,
+	"python": `# Code generated by promc; DO NOT EDIT.
+{{range .Metrics}}
+{{with .Help}}# {{.}}
+{{end}}{{.Name}} = "{{.Value}}"
+{{- end}}
+{{- if .Labels}}
+{{range .Labels}}
+{{.Name}} = "{{.Value}}"
+{{- end}}
+{{- end}}
+`,
+}
+
+// constant is a named string constant in a constants file.
+type constant struct {
+	Name  string
+	Value string
+	Help  string
+}
+
+func newConstantsCmd() *cobra.Command {
+	var configPaths []string
+	var outputPath, lang, class, javaPackage string
+
+	cmd := &cobra.Command{
+		Use:   "constants",
+		Short: "Generates metric and label name constants for other languages",
+		Run: func(cmd *cobra.Command, args []string) {
+			config, err := loadConfigs(configPaths, false)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			if problems := collisionProblems(config); len(problems) > 0 {
+				fmt.Printf("metric collisions found:\n- %s\n", strings.Join(problems, "\n- "))
+				os.Exit(1)
+			}
+
+			source, err := generateConstants(config, lang, class, javaPackage)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			if outputPath == "" {
+				os.Stdout.Write(source)
+				return
+			}
+			err = os.WriteFile(outputPath, source, 0o644)
+			if err != nil {
+				fmt.Printf("error writing output file: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringArrayVarP(&configPaths, "config", "c", nil, "Path to the configuration file, may be repeated to merge several files (required)")
+	cmd.Flags().StringVarP(&outputPath, "output", "o", "", "Path to the output file, defaults to standard output")
+	cmd.Flags().StringVar(&lang, "lang", "", "Language of the constants: ts, java or python (required)")
+	cmd.Flags().StringVar(&class, "class", "MetricNames", "Name of the generated Java class")
+	cmd.Flags().StringVar(&javaPackage, "java-package", "", "Package of the generated Java class")
+	cmd.MarkFlagRequired("config")
+	cmd.MarkFlagRequired("lang")
+
+	return cmd
+}
+
+// generateConstants returns a source file in lang declaring a constant for
+// the full name of every metric in config and for every label name.
+func generateConstants(config *promc.MetricConfig, lang, class, javaPackage string) ([]byte, error) {
+	text, ok := constantsTemplates[lang]
+	if !ok {
+		return nil, fmt.Errorf("invalid language %q, must be ts, java or python", lang)
+	}
+
+	data := struct {
+		Class   string
+		Package string
+		Metrics []constant
+		Labels  []constant
+	}{Class: class, Package: javaPackage}
+
+	labels := make(map[string]bool)
+	for _, metric := range config.Metrics {
+		data.Metrics = append(data.Metrics, constant{
+			Name:  strings.ToUpper(strings.ReplaceAll(metric.Name, ":", "_")),
+			Value: prometheus.BuildFQName(metric.Namespace, "", metric.Name),
+			Help:  strings.Join(strings.Fields(metric.Help), " "),
+		})
+		for _, label := range metric.Labels {
+			labels[label.Name] = true
+		}
+	}
+	var labelNames []string
+	for label := range labels {
+		labelNames = append(labelNames, label)
+	}
+	sort.Strings(labelNames)
+	for _, label := range labelNames {
+		data.Labels = append(data.Labels, constant{Name: "LABEL_" + strings.ToUpper(label), Value: label})
+	}
+
+	names := make(map[string]bool)
+	for _, c := range append(append([]constant(nil), data.Metrics...), data.Labels...) {
+		if names[c.Name] {
+			return nil, fmt.Errorf("constant %s is declared twice, rename the metric or label", c.Name)
+		}
+		names[c.Name] = true
+	}
+
+	funcs := template.FuncMap{
+		// doc makes text safe to use in a /** */ comment.
+		"doc": func(text string) string {
+			return strings.ReplaceAll(text, "*/", "*&#47;")
+		},
+	}
+	tmpl, err := template.New(lang).Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing template: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("error executing template: %v", err)
+	}
+	return buf.Bytes(), nil
+}
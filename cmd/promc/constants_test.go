@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/remiges-tech/serversage/promc"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the tests")
+
+func TestGenerateConstants(t *testing.T) {
+	config, err := loadConfig(filepath.Join("testdata", "constants", "metrics.json"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		lang        string
+		class       string
+		javaPackage string
+		golden      string
+	}{
+		{lang: "ts", golden: "metrics.ts"},
+		{lang: "java", class: "MetricNames", javaPackage: "com.example.shop", golden: "MetricNames.java"},
+		{lang: "java", class: "ShopMetrics", golden: "ShopMetrics.java"},
+		{lang: "python", golden: "metrics.py"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			got, err := generateConstants(config, tt.lang, tt.class, tt.javaPackage)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			goldenPath := filepath.Join("testdata", "constants", tt.golden)
+			if *update {
+				if err := os.WriteFile(goldenPath, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("generateConstants() differs from %s, run go test -run TestGenerateConstants -update:\n%s", goldenPath, got)
+			}
+		})
+	}
+}
+
+func TestGenerateConstantsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		metrics []promc.Metric
+		lang    string
+		wantErr string
+	}{
+		{
+			name:    "invalid language",
+			metrics: []promc.Metric{{Name: "orders_total"}},
+			lang:    "go",
+			wantErr: `invalid language "go", must be ts, java or python`,
+		},
+		{
+			name: "metric and label constant",
+			metrics: []promc.Metric{
+				{Name: "orders_total", Labels: []promc.Label{{Name: "method"}}},
+				{Name: "label_method"},
+			},
+			lang:    "ts",
+			wantErr: "constant LABEL_METHOD is declared twice, rename the metric or label",
+		},
+		{
+			name: "recording rule name",
+			metrics: []promc.Metric{
+				{Name: "job:orders:rate5m"},
+				{Name: "job_orders_rate5m"},
+			},
+			lang:    "python",
+			wantErr: "constant JOB_ORDERS_RATE5M is declared twice, rename the metric or label",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := generateConstants(&promc.MetricConfig{Metrics: tt.metrics}, tt.lang, "MetricNames", "")
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("generateConstants() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/remiges-tech/serversage/promc"
+	"github.com/spf13/cobra"
+)
+
+// Change describes a single difference between two configurations.
+type Change struct {
+	Metric   string `json:"metric,omitempty"`
+	Kind     string `json:"kind"`
+	Detail   string `json:"detail"`
+	Breaking bool   `json:"breaking"`
+}
+
+// changeReport is the JSON form of the output of promc diff.
+type changeReport struct {
+	Breaking int      `json:"breaking"`
+	Changes  []Change `json:"changes"`
+}
+
+func newDiffCmd() *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "diff OLD_CONFIG NEW_CONFIG",
+		Short: "Classifies the changes between two configurations as breaking or safe",
+		Long: `Compares two configurations and reports every added, removed or changed metric.
+Changes that break existing dashboards and alerts (removed metrics or labels,
+changed types or buckets) are reported as breaking, and the command exits with a
+non-zero status if there are any. The json and markdown formats are meant for
+automation, e.g. posting the report as a pull request comment.`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			oldConfig, err := loadConfig(args[0], false)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			newConfig, err := loadConfig(args[1], false)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			changes := diffConfigs(oldConfig, newConfig)
+			breaking := 0
+			for _, change := range changes {
+				if change.Breaking {
+					breaking++
+				}
+			}
+
+			switch format {
+			case "text":
+				for _, change := range changes {
+					class := "safe"
+					if change.Breaking {
+						class = "BREAKING"
+					}
+					fmt.Printf("%-8s  %s: %s\n", class, changeSubject(change), change.Detail)
+				}
+				if breaking > 0 {
+					fmt.Printf("%d breaking change(s) found\n", breaking)
+				}
+			case "json":
+				report, err := jsonChanges(changes, breaking)
+				if err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+				fmt.Println(string(report))
+			case "markdown":
+				fmt.Print(markdownChanges(changes, breaking))
+			default:
+				fmt.Printf("invalid format %q, must be text, json or markdown\n", format)
+				os.Exit(1)
+			}
+			if breaking > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "text", "Output format: text, json or markdown")
+
+	return cmd
+}
+
+// diffConfigs returns the changes needed to turn oldConfig into newConfig.
+// Config-wide changes are reported with an empty metric name.
+func diffConfigs(oldConfig, newConfig *promc.MetricConfig) []Change {
+	var changes []Change
+
+	if oldConfig.SchemaVersion != newConfig.SchemaVersion {
+		changes = append(changes, Change{
+			Kind:   "schema_version_changed",
+			Detail: fmt.Sprintf("schema version changed from %d to %d", oldConfig.SchemaVersion, newConfig.SchemaVersion),
+		})
+	}
+
+	oldMetrics := make(map[string]promc.Metric)
+	for _, metric := range oldConfig.Metrics {
+		oldMetrics[metricFullName(metric)] = metric
+	}
+	newMetrics := make(map[string]promc.Metric)
+	for _, metric := range newConfig.Metrics {
+		newMetrics[metricFullName(metric)] = metric
+	}
+
+	matched := make(map[string]bool)
+	for _, oldMetric := range oldConfig.Metrics {
+		newMetric, ok := newMetrics[metricFullName(oldMetric)]
+		if !ok {
+			// A metric moved to another namespace is compared with its new
+			// version, which reports the namespace change.
+			newMetric, ok = movedMetric(oldMetric, newConfig, oldMetrics, matched)
+		}
+		if !ok {
+			changes = append(changes, Change{
+				Metric:   metricFullName(oldMetric),
+				Kind:     "metric_removed",
+				Detail:   "metric removed",
+				Breaking: true,
+			})
+			continue
+		}
+		matched[metricFullName(newMetric)] = true
+		changes = append(changes, diffMetrics(oldMetric, newMetric)...)
+	}
+	for i, change := range changes {
+		if oldMetric, ok := oldMetrics[change.Metric]; ok {
+			changes[i].Breaking = stabilityBreaking(oldMetric.Stability, change)
+		}
+	}
+	for _, newMetric := range newConfig.Metrics {
+		if !matched[metricFullName(newMetric)] {
+			changes = append(changes, Change{
+				Metric: metricFullName(newMetric),
+				Kind:   "metric_added",
+				Detail: "metric added",
+			})
+		}
+	}
+
+	return changes
+}
+
+// movedMetric returns the metric of newConfig with the name of oldMetric in
+// another namespace, skipping metrics that exist in oldMetrics under the same
+// full name or were already matched.
+func movedMetric(oldMetric promc.Metric, newConfig *promc.MetricConfig, oldMetrics map[string]promc.Metric, matched map[string]bool) (promc.Metric, bool) {
+	for _, newMetric := range newConfig.Metrics {
+		fullName := metricFullName(newMetric)
+		if newMetric.Name != oldMetric.Name || matched[fullName] {
+			continue
+		}
+		if _, ok := oldMetrics[fullName]; ok {
+			continue
+		}
+		return newMetric, true
+	}
+	return promc.Metric{}, false
+}
+
+// metricFullName returns the name metric is exposed as.
+func metricFullName(metric promc.Metric) string {
+	return prometheus.BuildFQName(metric.Namespace, "", metric.Name)
+}
+
+// diffMetrics returns the changes between two versions of the same metric.
+func diffMetrics(oldMetric, newMetric promc.Metric) []Change {
+	var changes []Change
+	change := func(kind string, breaking bool, format string, a ...interface{}) {
+		changes = append(changes, Change{
+			Metric:   metricFullName(oldMetric),
+			Kind:     kind,
+			Detail:   fmt.Sprintf(format, a...),
+			Breaking: breaking,
+		})
+	}
+
+	if oldMetric.Namespace != newMetric.Namespace {
+		change("namespace_changed", true, "namespace changed from %q to %q", oldMetric.Namespace, newMetric.Namespace)
+	}
+	if oldMetric.Type != newMetric.Type {
+		change("type_changed", true, "type changed from %s to %s", oldMetric.Type, newMetric.Type)
+	}
+
+	oldLabels := labelNames(oldMetric.Labels)
+	newLabels := labelNames(newMetric.Labels)
+	for _, label := range oldLabels {
+		if !contains(newLabels, label) {
+			change("label_removed", true, "label %s removed", label)
+		}
+	}
+	for _, label := range newLabels {
+		if !contains(oldLabels, label) {
+			change("label_added", false, "label %s added", label)
+		}
+	}
+
+	for _, state := range oldMetric.States {
+		if !contains(newMetric.States, state) {
+			change("state_removed", true, "state %s removed", state)
+		}
+	}
+	for _, state := range newMetric.States {
+		if !contains(oldMetric.States, state) {
+			change("state_added", false, "state %s added", state)
+		}
+	}
+
+	for _, label := range sortedKeys(oldMetric.ConstLabels) {
+		newValue, ok := newMetric.ConstLabels[label]
+		if !ok {
+			change("const_label_removed", true, "const label %s removed", label)
+		} else if newValue != oldMetric.ConstLabels[label] {
+			change("const_label_changed", true, "const label %s changed from %q to %q", label, oldMetric.ConstLabels[label], newValue)
+		}
+	}
+	for _, label := range sortedKeys(newMetric.ConstLabels) {
+		if _, ok := oldMetric.ConstLabels[label]; !ok {
+			change("const_label_added", false, "const label %s added", label)
+		}
+	}
+
+	oldBuckets, newBuckets := histogramBuckets(oldMetric), histogramBuckets(newMetric)
+	if !reflect.DeepEqual(oldBuckets, newBuckets) {
+		change("buckets_changed", true, "buckets changed from %s to %s", formatBuckets(oldBuckets), formatBuckets(newBuckets))
+	}
+	for _, quantile := range sortedQuantiles(oldMetric.Objectives) {
+		newError, ok := newMetric.Objectives[quantile]
+		if !ok {
+			change("quantile_removed", true, "quantile %s removed", promc.FormatFloat(quantile))
+		} else if newError != oldMetric.Objectives[quantile] {
+			change("quantile_error_changed", false, "error of quantile %s changed from %s to %s", promc.FormatFloat(quantile), promc.FormatFloat(oldMetric.Objectives[quantile]), promc.FormatFloat(newError))
+		}
+	}
+	for _, quantile := range sortedQuantiles(newMetric.Objectives) {
+		if _, ok := oldMetric.Objectives[quantile]; !ok {
+			change("quantile_added", false, "quantile %s added", promc.FormatFloat(quantile))
+		}
+	}
+	if oldMetric.MaxAge != newMetric.MaxAge || oldMetric.AgeBuckets != newMetric.AgeBuckets {
+		change("window_changed", false, "summary window changed")
+	}
+	if oldMetric.Help != newMetric.Help {
+		change("help_changed", false, "help text changed")
+	}
+	if !oldMetric.Deprecated && newMetric.Deprecated {
+		change("metric_deprecated", false, "metric deprecated")
+	}
+	if oldMetric.Stability != newMetric.Stability {
+		change("stability_changed", false, "stability changed from %s to %s", stabilityLevel(oldMetric.Stability), stabilityLevel(newMetric.Stability))
+	}
+
+	return changes
+}
+
+// stabilityBreaking reports whether change to a metric with the given
+// stability level is breaking. Changes to alpha metrics never are, while any
+// change to a stable metric except its help text and its deprecation is.
+func stabilityBreaking(stability string, change Change) bool {
+	switch stability {
+	case "alpha":
+		return false
+	case "stable":
+		return change.Kind != "help_changed" && change.Kind != "metric_deprecated"
+	}
+	return change.Breaking
+}
+
+// stabilityLevel returns stability, or "unspecified" if it is empty.
+func stabilityLevel(stability string) string {
+	if stability == "" {
+		return "unspecified"
+	}
+	return stability
+}
+
+// changeSubject returns the metric name of change, or "(config)" for a
+// config-wide change.
+func changeSubject(change Change) string {
+	if change.Metric == "" {
+		return "(config)"
+	}
+	return change.Metric
+}
+
+// jsonChanges returns changes as an indented JSON object, with the number of
+// breaking changes among them.
+func jsonChanges(changes []Change, breaking int) ([]byte, error) {
+	if changes == nil {
+		changes = []Change{}
+	}
+	return json.MarshalIndent(changeReport{Breaking: breaking, Changes: changes}, "", "  ")
+}
+
+// markdownChanges returns changes as a Markdown table, headed by the number
+// of breaking and safe changes among them.
+func markdownChanges(changes []Change, breaking int) string {
+	var b strings.Builder
+	b.WriteString("### Metrics changes\n\n")
+	if len(changes) == 0 {
+		b.WriteString("No changes.\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "**%d breaking**, %d safe change(s).\n\n", breaking, len(changes)-breaking)
+	b.WriteString("| | Metric | Kind | Change |\n")
+	b.WriteString("|---|---|---|---|\n")
+	for _, change := range changes {
+		class := "safe"
+		if change.Breaking {
+			class = "**BREAKING**"
+		}
+		fmt.Fprintf(&b, "| %s | `%s` | %s | %s |\n", class, changeSubject(change), change.Kind, strings.ReplaceAll(change.Detail, "|", "\\|"))
+	}
+	return b.String()
+}
+
+func labelNames(labels []promc.Label) []string {
+	names := make([]string, len(labels))
+	for i, label := range labels {
+		names[i] = label.Name
+	}
+	return names
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedQuantiles(objectives promc.Objectives) []float64 {
+	quantiles := make([]float64, 0, len(objectives))
+	for quantile := range objectives {
+		quantiles = append(quantiles, quantile)
+	}
+	sort.Float64s(quantiles)
+	return quantiles
+}
+
+// histogramBuckets returns the buckets metric is registered with. Histograms
+// without buckets get the client library's default buckets, and other metrics none.
+func histogramBuckets(metric promc.Metric) []float64 {
+	if len(metric.Buckets) > 0 {
+		return metric.Buckets
+	}
+	if metric.Type == "histogram" {
+		return prometheus.DefBuckets
+	}
+	return nil
+}
+
+func formatBuckets(buckets []float64) string {
+	values := make([]string, len(buckets))
+	for i, bucket := range buckets {
+		values[i] = fmt.Sprint(bucket)
+	}
+	return "[" + strings.Join(values, ", ") + "]"
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/remiges-tech/serversage/promc"
+)
+
+func TestDiffConfigs(t *testing.T) {
+	counter := promc.Metric{Name: "orders_total", Type: "counter", Labels: []promc.Label{{Name: "status"}}}
+	histogram := promc.Metric{Name: "latency_seconds", Type: "histogram"}
+	summary := promc.Metric{Name: "size_bytes", Type: "summary", Objectives: promc.Objectives{0.5: 0.05, 0.9: 0.01}}
+
+	with := func(metric promc.Metric, change func(*promc.Metric)) promc.Metric {
+		metric.Labels = append([]promc.Label(nil), metric.Labels...)
+		change(&metric)
+		return metric
+	}
+
+	tests := []struct {
+		name       string
+		oldMetrics []promc.Metric
+		newMetrics []promc.Metric
+		want       []string
+	}{
+		{
+			name:       "unchanged",
+			oldMetrics: []promc.Metric{counter, histogram, summary},
+			newMetrics: []promc.Metric{counter, histogram, summary},
+		},
+		{
+			name:       "metric removed and added",
+			oldMetrics: []promc.Metric{counter},
+			newMetrics: []promc.Metric{histogram},
+			want:       []string{"orders_total metric_removed breaking", "latency_seconds metric_added safe"},
+		},
+		{
+			name:       "label removed",
+			oldMetrics: []promc.Metric{counter},
+			newMetrics: []promc.Metric{with(counter, func(m *promc.Metric) { m.Labels = nil })},
+			want:       []string{"orders_total label_removed breaking"},
+		},
+		{
+			name:       "label added",
+			oldMetrics: []promc.Metric{counter},
+			newMetrics: []promc.Metric{with(counter, func(m *promc.Metric) { m.Labels = append(m.Labels, promc.Label{Name: "region"}) })},
+			want:       []string{"orders_total label_added safe"},
+		},
+		{
+			name:       "type changed",
+			oldMetrics: []promc.Metric{counter},
+			newMetrics: []promc.Metric{with(counter, func(m *promc.Metric) { m.Type = "gauge" })},
+			want:       []string{"orders_total type_changed breaking"},
+		},
+		{
+			name:       "buckets changed",
+			oldMetrics: []promc.Metric{histogram},
+			newMetrics: []promc.Metric{with(histogram, func(m *promc.Metric) { m.Buckets = promc.Buckets{0.1, 1} })},
+			want:       []string{"latency_seconds buckets_changed breaking"},
+		},
+		{
+			name:       "default buckets written out",
+			oldMetrics: []promc.Metric{histogram},
+			newMetrics: []promc.Metric{with(histogram, func(m *promc.Metric) {
+				m.Buckets = promc.Buckets{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+			})},
+		},
+		{
+			name:       "empty buckets",
+			oldMetrics: []promc.Metric{histogram},
+			newMetrics: []promc.Metric{with(histogram, func(m *promc.Metric) { m.Buckets = promc.Buckets{} })},
+		},
+		{
+			name:       "quantiles changed",
+			oldMetrics: []promc.Metric{summary},
+			newMetrics: []promc.Metric{with(summary, func(m *promc.Metric) { m.Objectives = promc.Objectives{0.5: 0.01, 0.99: 0.001} })},
+			want: []string{
+				"size_bytes quantile_error_changed safe",
+				"size_bytes quantile_removed breaking",
+				"size_bytes quantile_added safe",
+			},
+		},
+		{
+			name:       "same name in two namespaces",
+			oldMetrics: []promc.Metric{with(counter, func(m *promc.Metric) { m.Namespace = "shop" }), with(counter, func(m *promc.Metric) { m.Namespace = "billing" })},
+			newMetrics: []promc.Metric{with(counter, func(m *promc.Metric) { m.Namespace = "shop" }), with(counter, func(m *promc.Metric) { m.Namespace = "billing"; m.Labels = nil })},
+			want:       []string{"billing_orders_total label_removed breaking"},
+		},
+		{
+			name:       "namespace changed",
+			oldMetrics: []promc.Metric{with(counter, func(m *promc.Metric) { m.Namespace = "shop" })},
+			newMetrics: []promc.Metric{with(counter, func(m *promc.Metric) { m.Namespace = "store" })},
+			want:       []string{"shop_orders_total namespace_changed breaking"},
+		},
+		{
+			name:       "metric copied to another namespace",
+			oldMetrics: []promc.Metric{with(counter, func(m *promc.Metric) { m.Namespace = "shop" })},
+			newMetrics: []promc.Metric{with(counter, func(m *promc.Metric) { m.Namespace = "shop" }), with(counter, func(m *promc.Metric) { m.Namespace = "store" })},
+			want:       []string{"store_orders_total metric_added safe"},
+		},
+		{
+			name:       "alpha metric",
+			oldMetrics: []promc.Metric{with(counter, func(m *promc.Metric) { m.Stability = "alpha" })},
+			newMetrics: []promc.Metric{with(counter, func(m *promc.Metric) { m.Stability = "alpha"; m.Labels = nil; m.Type = "gauge" })},
+			want:       []string{"orders_total type_changed safe", "orders_total label_removed safe"},
+		},
+		{
+			name:       "alpha metric removed",
+			oldMetrics: []promc.Metric{with(counter, func(m *promc.Metric) { m.Stability = "alpha" })},
+			want:       []string{"orders_total metric_removed safe"},
+		},
+		{
+			name:       "stable metric",
+			oldMetrics: []promc.Metric{with(counter, func(m *promc.Metric) { m.Stability = "stable" })},
+			newMetrics: []promc.Metric{with(counter, func(m *promc.Metric) {
+				m.Stability = "stable"
+				m.Labels = append(m.Labels, promc.Label{Name: "region"})
+				m.Help = "Orders placed."
+				m.Deprecated = true
+			})},
+			want: []string{"orders_total label_added breaking", "orders_total help_changed safe", "orders_total metric_deprecated safe"},
+		},
+		{
+			name:       "stable metric demoted",
+			oldMetrics: []promc.Metric{with(counter, func(m *promc.Metric) { m.Stability = "stable" })},
+			newMetrics: []promc.Metric{with(counter, func(m *promc.Metric) { m.Stability = "beta" })},
+			want:       []string{"orders_total stability_changed breaking"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := diffConfigs(&promc.MetricConfig{Metrics: tt.oldMetrics}, &promc.MetricConfig{Metrics: tt.newMetrics})
+			var got []string
+			for _, change := range changes {
+				breaking := "safe"
+				if change.Breaking {
+					breaking = "breaking"
+				}
+				got = append(got, fmt.Sprintf("%s %s %s", change.Metric, change.Kind, breaking))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffConfigs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffConfigsSchemaVersion(t *testing.T) {
+	changes := diffConfigs(&promc.MetricConfig{SchemaVersion: 1}, &promc.MetricConfig{SchemaVersion: 2})
+	want := []Change{{Kind: "schema_version_changed", Detail: "schema version changed from 1 to 2"}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("diffConfigs() = %+v, want %+v", changes, want)
+	}
+}
+
+func TestStabilityBreaking(t *testing.T) {
+	tests := []struct {
+		stability string
+		change    Change
+		want      bool
+	}{
+		{"", Change{Kind: "label_removed", Breaking: true}, true},
+		{"", Change{Kind: "label_added"}, false},
+		{"beta", Change{Kind: "label_removed", Breaking: true}, true},
+		{"alpha", Change{Kind: "metric_removed", Breaking: true}, false},
+		{"alpha", Change{Kind: "type_changed", Breaking: true}, false},
+		{"stable", Change{Kind: "label_added"}, true},
+		{"stable", Change{Kind: "quantile_error_changed"}, true},
+		{"stable", Change{Kind: "help_changed"}, false},
+		{"stable", Change{Kind: "metric_deprecated"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.stability+"/"+tt.change.Kind, func(t *testing.T) {
+			if got := stabilityBreaking(tt.stability, tt.change); got != tt.want {
+				t.Errorf("stabilityBreaking(%q, %s) = %v, want %v", tt.stability, tt.change.Kind, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChangeOutput(t *testing.T) {
+	changes := []Change{
+		{Kind: "schema_version_changed", Detail: "schema version changed from 1 to 2"},
+		{Metric: "shop_orders_total", Kind: "label_removed", Detail: "label status removed", Breaking: true},
+		{Metric: "shop_orders_total", Kind: "const_label_changed", Detail: `const label env changed from "a|b" to "c"`},
+	}
+
+	tests := []struct {
+		name    string
+		changes []Change
+		format  func([]Change, int) (string, error)
+		want    string
+	}{
+		{
+			name:    "json",
+			changes: changes,
+			format:  jsonOutput,
+			want: `{
+  "breaking": 1,
+  "changes": [
+    {
+      "kind": "schema_version_changed",
+      "detail": "schema version changed from 1 to 2",
+      "breaking": false
+    },
+    {
+      "metric": "shop_orders_total",
+      "kind": "label_removed",
+      "detail": "label status removed",
+      "breaking": true
+    },
+    {
+      "metric": "shop_orders_total",
+      "kind": "const_label_changed",
+      "detail": "const label env changed from \"a|b\" to \"c\"",
+      "breaking": false
+    }
+  ]
+}`,
+		},
+		{
+			name:   "json without changes",
+			format: jsonOutput,
+			want: `{
+  "breaking": 0,
+  "changes": []
+}`,
+		},
+		{
+			name:    "markdown",
+			changes: changes,
+			format:  markdownOutput,
+			want: "### Metrics changes\n\n" +
+				"**1 breaking**, 2 safe change(s).\n\n" +
+				"| | Metric | Kind | Change |\n" +
+				"|---|---|---|---|\n" +
+				"| safe | `(config)` | schema_version_changed | schema version changed from 1 to 2 |\n" +
+				"| **BREAKING** | `shop_orders_total` | label_removed | label status removed |\n" +
+				"| safe | `shop_orders_total` | const_label_changed | const label env changed from \"a\\|b\" to \"c\" |\n",
+		},
+		{
+			name:   "markdown without changes",
+			format: markdownOutput,
+			want:   "### Metrics changes\n\nNo changes.\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breaking := 0
+			for _, change := range tt.changes {
+				if change.Breaking {
+					breaking++
+				}
+			}
+			got, err := tt.format(tt.changes, breaking)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func jsonOutput(changes []Change, breaking int) (string, error) {
+	report, err := jsonChanges(changes, breaking)
+	return string(report), err
+}
+
+func markdownOutput(changes []Change, breaking int) (string, error) {
+	return markdownChanges(changes, breaking), nil
+}
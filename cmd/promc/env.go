@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/prometheus/common/model"
+	"github.com/remiges-tech/serversage/promc"
+)
+
+// expandEnv resolves ${ENV_VAR} and ${ENV_VAR:-default} references in s, and
+// turns $$ into a literal $. Variables are looked up with lookup. When strict
+// is set, referencing an unset variable without a default is an error;
+// otherwise it expands to the empty string.
+func expandEnv(s string, strict bool, lookup func(string) (string, bool)) (string, error) {
+	var missing []string
+	expanded := os.Expand(s, func(ref string) string {
+		if ref == "$" {
+			return "$"
+		}
+		name, def, hasDefault := strings.Cut(ref, ":-")
+		if value, ok := lookup(name); ok && value != "" {
+			return value
+		}
+		if hasDefault {
+			return def
+		}
+		if strict {
+			missing = append(missing, name)
+		}
+		return ""
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("unset environment variable(s) %s in %q", strings.Join(missing, ", "), s)
+	}
+	return expanded, nil
+}
+
+// noEnv is a lookup function for an empty environment.
+func noEnv(string) (string, bool) {
+	return "", false
+}
+
+// expandConfigEnv resolves environment variable references in the namespace
+// and the const label values of every metric of c. Unset variables in the
+// namespace are always an error, since dropping it would rename every metric.
+func expandConfigEnv(c *promc.MetricConfig, strict bool, lookup func(string) (string, bool)) error {
+	var err error
+	c.Namespace, err = expandEnv(c.Namespace, true, lookup)
+	if err != nil {
+		return fmt.Errorf("namespace: %v", err)
+	}
+	if c.Namespace != "" && !model.IsValidMetricName(model.LabelValue(c.Namespace)) {
+		return fmt.Errorf("namespace: %q is not a valid metric name prefix", c.Namespace)
+	}
+	for i := range c.Metrics {
+		metric := &c.Metrics[i]
+		for label, value := range metric.ConstLabels {
+			metric.ConstLabels[label], err = expandEnv(value, strict, lookup)
+			if err != nil {
+				return fmt.Errorf("metric %s: const label %s: %v", metric.Name, label, err)
+			}
+		}
+	}
+	return nil
+}
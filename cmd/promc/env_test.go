@@ -0,0 +1,135 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/remiges-tech/serversage/promc"
+)
+
+// testEnv is a lookup function for a fixed environment.
+func testEnv(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	env := testEnv(map[string]string{"APP": "shop", "EMPTY": ""})
+
+	tests := []struct {
+		name    string
+		s       string
+		strict  bool
+		want    string
+		wantErr string
+	}{
+		{name: "no reference", s: "shop", want: "shop"},
+		{name: "set", s: "${APP}_api", want: "shop_api"},
+		{name: "unbraced", s: "$APP", want: "shop"},
+		{name: "default unused", s: "${APP:-store}", want: "shop"},
+		{name: "default", s: "${REGION:-eu}", want: "eu"},
+		{name: "empty uses default", s: "${EMPTY:-eu}", want: "eu"},
+		{name: "empty default", s: "${REGION:-}", strict: true, want: ""},
+		{name: "escaped dollar", s: "cost_$$", want: "cost_$"},
+		{name: "escaped reference", s: "$${APP}", want: "${APP}"},
+		{name: "unset", s: "${REGION}_eu", want: "_eu"},
+		{name: "unset strict", s: "${REGION}_${ZONE}", strict: true, wantErr: `unset environment variable(s) REGION, ZONE in "${REGION}_${ZONE}"`},
+		{name: "empty strict", s: "${EMPTY}", strict: true, wantErr: `unset environment variable(s) EMPTY in "${EMPTY}"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandEnv(tt.s, tt.strict, env)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("expandEnv(%q) error = %v, want %s", tt.s, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("expandEnv(%q) = %q, want %q", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandConfigEnv(t *testing.T) {
+	env := testEnv(map[string]string{"APP": "shop", "ENV": "prod", "BAD": "shop-api"})
+
+	tests := []struct {
+		name            string
+		namespace       string
+		constLabels     map[string]string
+		strict          bool
+		wantNamespace   string
+		wantConstLabels map[string]string
+		wantErr         string
+	}{
+		{
+			name:            "expanded",
+			namespace:       "${APP}",
+			constLabels:     map[string]string{"env": "${ENV}", "region": "${REGION:-eu}"},
+			wantNamespace:   "shop",
+			wantConstLabels: map[string]string{"env": "prod", "region": "eu"},
+		},
+		{
+			name:            "unset const label",
+			namespace:       "shop",
+			constLabels:     map[string]string{"zone": "${ZONE}"},
+			wantNamespace:   "shop",
+			wantConstLabels: map[string]string{"zone": ""},
+		},
+		{
+			name:        "unset const label strict",
+			namespace:   "shop",
+			constLabels: map[string]string{"zone": "${ZONE}"},
+			strict:      true,
+			wantErr:     `metric orders_total: const label zone: unset environment variable(s) ZONE in "${ZONE}"`,
+		},
+		{
+			name:      "unset namespace",
+			namespace: "${NAMESPACE}",
+			wantErr:   `namespace: unset environment variable(s) NAMESPACE in "${NAMESPACE}"`,
+		},
+		{
+			name:          "namespace default",
+			namespace:     "${NAMESPACE:-store}",
+			wantNamespace: "store",
+		},
+		{
+			name:      "invalid namespace",
+			namespace: "${BAD}",
+			wantErr:   `namespace: "shop-api" is not a valid metric name prefix`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &promc.MetricConfig{
+				Namespace: tt.namespace,
+				Metrics:   []promc.Metric{{Name: "orders_total", ConstLabels: tt.constLabels}},
+			}
+			err := expandConfigEnv(config, tt.strict, env)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("expandConfigEnv() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Namespace != tt.wantNamespace {
+				t.Errorf("namespace = %q, want %q", config.Namespace, tt.wantNamespace)
+			}
+			if got := config.Metrics[0].ConstLabels; !reflect.DeepEqual(got, tt.wantConstLabels) {
+				t.Errorf("const labels = %v, want %v", got, tt.wantConstLabels)
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/remiges-tech/serversage/promc"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// Canonical key orders of the objects in a configuration. Keys that are not
+// listed follow in alphabetical order.
+var (
+	configKeyOrder  = []string{"schema_version", "namespace", "api", "interface", "di", "build_tags", "header", "import_aliases", "grpc", "includes", "metrics"}
+	metricKeyOrder  = []string{"name", "type", "help", "description", "unit", "labels", "states", "const_labels", "buckets", "objectives", "max_age", "age_buckets", "exemplars", "deprecated", "removed_after", "stability"}
+	labelKeyOrder   = []string{"name", "allowed_values", "on_unexpected", "default", "required"}
+	grpcKeyOrder    = []string{"server", "client"}
+	grpcMetricOrder = []string{"requests_metric", "latency_metric"}
+)
+
+// orderedField is a key and its value in an orderedObject.
+type orderedField struct {
+	Key   string
+	Value interface{}
+}
+
+// orderedObject is an object whose keys are encoded in a fixed order.
+type orderedObject []orderedField
+
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := marshalJSON(field.Key, "")
+		if err != nil {
+			return nil, err
+		}
+		value, err := marshalJSON(field.Value, "")
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (o orderedObject) MarshalYAML() (interface{}, error) {
+	slice := make(yaml.MapSlice, len(o))
+	for i, field := range o {
+		slice[i] = yaml.MapItem{Key: field.Key, Value: field.Value}
+	}
+	return slice, nil
+}
+
+func newFmtCmd() *cobra.Command {
+	var write, list bool
+	var to string
+
+	cmd := &cobra.Command{
+		Use:   "fmt CONFIG...",
+		Short: "Rewrites configuration files in canonical form",
+		Long: `Formats configuration files canonically: metrics are sorted by name, keys
+follow a fixed order, labels without constraints are written as plain names and
+bucket expressions are normalized. The result is printed to standard output
+unless -w or -l is given. With --to a JSON configuration is converted to YAML or
+the other way round.`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if to != "" && to != "json" && to != "yaml" {
+				fmt.Printf("invalid format %q, must be json or yaml\n", to)
+				os.Exit(1)
+			}
+
+			unformatted := 0
+			for _, path := range args {
+				content, err := os.ReadFile(path)
+				if err != nil {
+					fmt.Printf("error reading config file: %v\n", err)
+					os.Exit(1)
+				}
+
+				format := to
+				if format == "" {
+					format = "json"
+					if isYAMLPath(path) {
+						format = "yaml"
+					}
+				}
+				formatted, err := formatConfig(content, isYAMLPath(path), format == "yaml")
+				if err != nil {
+					fmt.Printf("%s: %v\n", path, err)
+					os.Exit(1)
+				}
+
+				outputPath := strings.TrimSuffix(path, filepath.Ext(path)) + "." + format
+				if isYAMLPath(path) == (format == "yaml") {
+					outputPath = path
+				}
+				switch {
+				case list:
+					if outputPath != path || !bytes.Equal(content, formatted) {
+						fmt.Println(path)
+						unformatted++
+					}
+				case write:
+					err = os.WriteFile(outputPath, formatted, 0o644)
+					if err != nil {
+						fmt.Printf("error writing output file: %v\n", err)
+						os.Exit(1)
+					}
+				default:
+					os.Stdout.Write(formatted)
+				}
+			}
+			if unformatted > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVarP(&write, "write", "w", false, "Write the result to the file instead of standard output, next to it if --to changes the format")
+	cmd.Flags().BoolVarP(&list, "list", "l", false, "List the files whose formatting differs and exit with a non-zero status if there are any")
+	cmd.Flags().StringVar(&to, "to", "", "Output format: json or yaml, defaults to the format of each file")
+
+	return cmd
+}
+
+// isYAMLPath reports whether the file at path is a YAML configuration.
+func isYAMLPath(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// yamlToJSON converts a YAML document to JSON.
+func yamlToJSON(content []byte) ([]byte, error) {
+	var document interface{}
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonValue(document))
+}
+
+// jsonValue converts the maps decoded by the YAML package, which have
+// interface{} keys, to maps with string keys.
+func jsonValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		object := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			object[fmt.Sprint(key)] = jsonValue(item)
+		}
+		return object
+	case []interface{}:
+		for i, item := range value {
+			value[i] = jsonValue(item)
+		}
+		return value
+	}
+	return value
+}
+
+// formatConfig returns the canonical form of a configuration file, encoded as
+// YAML if toYAML is set and as JSON otherwise. The configuration is
+// formatted as written: includes and environment variables are not resolved.
+func formatConfig(content []byte, fromYAML, toYAML bool) ([]byte, error) {
+	document, err := decodeConfigDocument(content, fromYAML)
+	if err != nil {
+		return nil, err
+	}
+	return encodeConfigDocument(document, toYAML)
+}
+
+// decodeConfigDocument validates a configuration file and decodes it without
+// interpreting its values.
+func decodeConfigDocument(content []byte, fromYAML bool) (map[string]interface{}, error) {
+	if fromYAML {
+		var err error
+		content, err = yamlToJSON(content)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing config file: %v", err)
+		}
+	}
+	if err := validateConfig(content); err != nil {
+		return nil, fmt.Errorf("config validation failed: %v", err)
+	}
+
+	var document map[string]interface{}
+	if err := json.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("error parsing config file: %v", err)
+	}
+	return document, nil
+}
+
+// encodeConfigDocument returns the canonical encoding of a decoded
+// configuration, as YAML if toYAML is set and as JSON otherwise.
+func encodeConfigDocument(document map[string]interface{}, toYAML bool) ([]byte, error) {
+	canonical := canonicalConfig(document)
+	if toYAML {
+		return yaml.Marshal(canonical)
+	}
+	formatted, err := marshalJSON(canonical, "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(formatted, '\n'), nil
+}
+
+// marshalJSON encodes v as JSON indented by indent, leaving characters such
+// as < and > in strings unescaped.
+func marshalJSON(v interface{}, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", indent)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// canonicalConfig returns the canonical form of a decoded configuration.
+func canonicalConfig(document map[string]interface{}) orderedObject {
+	if metrics, ok := document["metrics"].([]interface{}); ok {
+		for i, metric := range metrics {
+			metrics[i] = canonicalMetric(metric.(map[string]interface{}))
+		}
+		sort.SliceStable(metrics, func(i, j int) bool {
+			return metricName(metrics[i]) < metricName(metrics[j])
+		})
+	}
+	if grpc, ok := document["grpc"].(map[string]interface{}); ok {
+		for key, metrics := range grpc {
+			grpc[key] = orderKeys(metrics.(map[string]interface{}), grpcMetricOrder)
+		}
+		document["grpc"] = orderKeys(grpc, grpcKeyOrder)
+	}
+	return orderKeys(document, configKeyOrder)
+}
+
+// canonicalMetric returns the canonical form of a decoded metric definition.
+func canonicalMetric(metric map[string]interface{}) orderedObject {
+	if labels, ok := metric["labels"].([]interface{}); ok {
+		for i, label := range labels {
+			object, ok := label.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if len(object) == 1 && object["name"] != nil {
+				labels[i] = object["name"]
+			} else {
+				labels[i] = orderKeys(object, labelKeyOrder)
+			}
+		}
+	}
+	if expr, ok := metric["buckets"].(string); ok {
+		metric["buckets"] = promc.NormalizeBucketExpr(expr)
+	}
+	return orderKeys(metric, metricKeyOrder)
+}
+
+// metricName returns the name of a canonical metric definition.
+func metricName(metric interface{}) string {
+	for _, field := range metric.(orderedObject) {
+		if field.Key == "name" {
+			name, _ := field.Value.(string)
+			return name
+		}
+	}
+	return ""
+}
+
+// orderKeys returns the fields of object with the keys listed in order first,
+// followed by the remaining keys in alphabetical order.
+func orderKeys(object map[string]interface{}, order []string) orderedObject {
+	var ordered orderedObject
+	for _, key := range order {
+		if value, ok := object[key]; ok {
+			ordered = append(ordered, orderedField{Key: key, Value: value})
+		}
+	}
+	var rest []string
+	for key := range object {
+		if !contains(order, key) {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+	for _, key := range rest {
+		ordered = append(ordered, orderedField{Key: key, Value: object[key]})
+	}
+	return ordered
+}
@@ -0,0 +1,138 @@
+package main
+
+import "testing"
+
+func TestFormatConfig(t *testing.T) {
+	const unorderedJSON = `{"metrics":[{"type":"histogram","labels":[{"name":"method"},{"name":"status","required":true,"allowed_values":["ok","failed"]}],"buckets":" exponential(0.001,2,15) ","name":"latency_seconds","help":"Latency."},{"help":"Orders.","name":"orders_total","type":"counter","stability":"stable"}],"namespace":"${APP:-shop}","schema_version":2}`
+	const unorderedYAML = `metrics:
+- type: histogram
+  labels:
+  - name: method
+  - required: true
+    name: status
+    allowed_values: [ok, failed]
+  buckets: " exponential(0.001,2,15) "
+  name: latency_seconds
+  help: Latency.
+- help: Orders.
+  name: orders_total
+  type: counter
+  stability: stable
+namespace: ${APP:-shop}
+schema_version: 2
+`
+	const canonicalJSON = `{
+  "schema_version": 2,
+  "namespace": "${APP:-shop}",
+  "metrics": [
+    {
+      "name": "latency_seconds",
+      "type": "histogram",
+      "help": "Latency.",
+      "labels": [
+        "method",
+        {
+          "name": "status",
+          "allowed_values": [
+            "ok",
+            "failed"
+          ],
+          "required": true
+        }
+      ],
+      "buckets": "exponential(0.001, 2, 15)"
+    },
+    {
+      "name": "orders_total",
+      "type": "counter",
+      "help": "Orders.",
+      "stability": "stable"
+    }
+  ]
+}
+`
+	const canonicalYAML = `schema_version: 2
+namespace: ${APP:-shop}
+metrics:
+- name: latency_seconds
+  type: histogram
+  help: Latency.
+  labels:
+  - method
+  - name: status
+    allowed_values:
+    - ok
+    - failed
+    required: true
+  buckets: exponential(0.001, 2, 15)
+- name: orders_total
+  type: counter
+  help: Orders.
+  stability: stable
+`
+
+	tests := []struct {
+		name     string
+		content  string
+		fromYAML bool
+		toYAML   bool
+		want     string
+	}{
+		{name: "json", content: unorderedJSON, want: canonicalJSON},
+		{name: "json to yaml", content: unorderedJSON, toYAML: true, want: canonicalYAML},
+		{name: "yaml", content: unorderedYAML, fromYAML: true, toYAML: true, want: canonicalYAML},
+		{name: "yaml to json", content: unorderedYAML, fromYAML: true, want: canonicalJSON},
+		{
+			name:    "grpc and includes",
+			content: `{"includes":["b.json","a.json"],"grpc":{"client":{"latency_metric":"latency_seconds","requests_metric":"requests_total"}},"metrics":[{"name":"requests_total","type":"counter","help":"Requests <per> client & server."}],"api":"struct"}`,
+			want: `{
+  "api": "struct",
+  "grpc": {
+    "client": {
+      "requests_metric": "requests_total",
+      "latency_metric": "latency_seconds"
+    }
+  },
+  "includes": [
+    "b.json",
+    "a.json"
+  ],
+  "metrics": [
+    {
+      "name": "requests_total",
+      "type": "counter",
+      "help": "Requests <per> client & server."
+    }
+  ]
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := formatConfig([]byte(tt.content), tt.fromYAML, tt.toYAML)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("formatConfig() =\n%s\nwant\n%s", got, tt.want)
+			}
+
+			again, err := formatConfig(got, tt.toYAML, tt.toYAML)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(again) != string(got) {
+				t.Errorf("formatting the canonical form changed it to\n%s", again)
+			}
+		})
+	}
+}
+
+func TestFormatConfigInvalid(t *testing.T) {
+	_, err := formatConfig([]byte(`{"metrics":[{"name":"orders_total","type":"counter","custom":1}]}`), false, false)
+	if err == nil {
+		t.Error("formatConfig() accepted a config that does not match the schema")
+	}
+}
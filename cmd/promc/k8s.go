@@ -0,0 +1,276 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/remiges-tech/serversage/promc"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// monitorManifest is a Prometheus Operator ServiceMonitor or PodMonitor.
+type monitorManifest struct {
+	APIVersion string          `yaml:"apiVersion"`
+	Kind       string          `yaml:"kind"`
+	Metadata   monitorMetadata `yaml:"metadata"`
+	Spec       monitorSpec     `yaml:"spec"`
+}
+
+type monitorMetadata struct {
+	Name      string            `yaml:"name"`
+	Namespace string            `yaml:"namespace,omitempty"`
+	Labels    map[string]string `yaml:"labels,omitempty"`
+}
+
+type monitorSpec struct {
+	Selector            monitorSelector   `yaml:"selector"`
+	Endpoints           []monitorEndpoint `yaml:"endpoints,omitempty"`
+	PodMetricsEndpoints []monitorEndpoint `yaml:"podMetricsEndpoints,omitempty"`
+}
+
+type monitorSelector struct {
+	MatchLabels map[string]string `yaml:"matchLabels"`
+}
+
+type monitorEndpoint struct {
+	Port              string           `yaml:"port"`
+	Path              string           `yaml:"path"`
+	Interval          string           `yaml:"interval"`
+	Relabelings       []monitorRelabel `yaml:"relabelings,omitempty"`
+	MetricRelabelings []monitorRelabel `yaml:"metricRelabelings,omitempty"`
+}
+
+type monitorRelabel struct {
+	SourceLabels []string `yaml:"sourceLabels,omitempty"`
+	Regex        string   `yaml:"regex,omitempty"`
+	TargetLabel  string   `yaml:"targetLabel,omitempty"`
+	Replacement  string   `yaml:"replacement,omitempty"`
+	Action       string   `yaml:"action"`
+}
+
+// monitorOptions are the settings of a monitor manifest that do not come from
+// the configuration.
+type monitorOptions struct {
+	Kind           string
+	Name           string
+	Namespace      string
+	Port           string
+	Path           string
+	Interval       string
+	Selector       map[string]string
+	Labels         map[string]string
+	TargetLabels   map[string]string
+	DropDeprecated bool
+	KeepDeclared   bool
+}
+
+func newK8sCmd() *cobra.Command {
+	var configPaths []string
+	var outputPath string
+	var options monitorOptions
+
+	cmd := &cobra.Command{
+		Use:   "k8s",
+		Short: "Generates a Prometheus Operator ServiceMonitor or PodMonitor for the configured metrics",
+		Run: func(cmd *cobra.Command, args []string) {
+			config, err := loadConfigs(configPaths, false)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			manifest, err := generateMonitor(config, options)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			if outputPath == "" {
+				os.Stdout.Write(manifest)
+				return
+			}
+			err = os.WriteFile(outputPath, manifest, 0o644)
+			if err != nil {
+				fmt.Printf("error writing output file: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringArrayVarP(&configPaths, "config", "c", nil, "Path to the configuration file, may be repeated to merge several files (required)")
+	cmd.Flags().StringVarP(&outputPath, "output", "o", "", "Path to the output file, defaults to standard output")
+	cmd.Flags().StringVar(&options.Kind, "kind", "ServiceMonitor", "Kind of the manifest: ServiceMonitor or PodMonitor")
+	cmd.Flags().StringVar(&options.Name, "name", "", "Name of the manifest, also used as the default app selector (required)")
+	cmd.Flags().StringVar(&options.Namespace, "namespace", "", "Kubernetes namespace of the manifest")
+	cmd.Flags().StringVar(&options.Port, "port", "metrics", "Name of the port serving the metrics")
+	cmd.Flags().StringVar(&options.Path, "path", "/metrics", "HTTP path serving the metrics")
+	cmd.Flags().StringVar(&options.Interval, "interval", "30s", "Scrape interval")
+	cmd.Flags().StringToStringVar(&options.Selector, "selector", nil, "Labels selecting the scraped services or pods, as name=value (default app=<name>)")
+	cmd.Flags().StringToStringVar(&options.Labels, "label", nil, "Label of the manifest itself, e.g. release=prometheus, as name=value")
+	cmd.Flags().StringToStringVar(&options.TargetLabels, "target-label", nil, "Label added to every scraped series, as name=value")
+	cmd.Flags().BoolVar(&options.DropDeprecated, "drop-deprecated", false, "Drop the series of deprecated metrics at scrape time")
+	cmd.Flags().BoolVar(&options.KeepDeclared, "keep-declared", false, "Drop every series that does not belong to a configured metric")
+	cmd.MarkFlagRequired("config")
+	cmd.MarkFlagRequired("name")
+
+	return cmd
+}
+
+// generateMonitor returns the YAML manifest of a monitor scraping the metrics
+// of config.
+func generateMonitor(config *promc.MetricConfig, options monitorOptions) ([]byte, error) {
+	if options.Kind != "ServiceMonitor" && options.Kind != "PodMonitor" {
+		return nil, fmt.Errorf("invalid kind %q, must be ServiceMonitor or PodMonitor", options.Kind)
+	}
+	if _, err := model.ParseDuration(options.Interval); err != nil {
+		return nil, fmt.Errorf("invalid interval: %v", err)
+	}
+	if err := validateTargetLabels(options.TargetLabels); err != nil {
+		return nil, err
+	}
+
+	selector := options.Selector
+	if len(selector) == 0 {
+		selector = map[string]string{"app": options.Name}
+	}
+
+	endpoint := monitorEndpoint{
+		Port:              options.Port,
+		Path:              options.Path,
+		Interval:          options.Interval,
+		Relabelings:       targetRelabelings(options.TargetLabels),
+		MetricRelabelings: metricRelabelings(config, options.DropDeprecated, options.KeepDeclared),
+	}
+
+	manifest := monitorManifest{
+		APIVersion: "monitoring.coreos.com/v1",
+		Kind:       options.Kind,
+		Metadata: monitorMetadata{
+			Name:      options.Name,
+			Namespace: options.Namespace,
+			Labels:    options.Labels,
+		},
+		Spec: monitorSpec{Selector: monitorSelector{MatchLabels: selector}},
+	}
+	if options.Kind == "PodMonitor" {
+		manifest.Spec.PodMetricsEndpoints = []monitorEndpoint{endpoint}
+	} else {
+		manifest.Spec.Endpoints = []monitorEndpoint{endpoint}
+	}
+
+	return yaml.Marshal(manifest)
+}
+
+// validateTargetLabels checks that the keys of labels are valid label names.
+func validateTargetLabels(labels map[string]string) error {
+	for name := range labels {
+		if !model.LabelName(name).IsValid() {
+			return fmt.Errorf("invalid target label name %q", name)
+		}
+	}
+	return nil
+}
+
+// targetRelabelings returns the relabeling rules that add labels to every
+// scraped series.
+func targetRelabelings(labels map[string]string) []monitorRelabel {
+	var relabelings []monitorRelabel
+	for _, name := range sortedKeys(labels) {
+		relabelings = append(relabelings, monitorRelabel{
+			TargetLabel: name,
+			Replacement: labels[name],
+			Action:      "replace",
+		})
+	}
+	return relabelings
+}
+
+// metricRelabelings returns the rules that keep only the series of configured
+// metrics and the self-metrics of the generated code if keepDeclared is set,
+// and drop the series of deprecated metrics if dropDeprecated is set.
+func metricRelabelings(config *promc.MetricConfig, dropDeprecated, keepDeclared bool) []monitorRelabel {
+	var relabelings []monitorRelabel
+	if keepDeclared {
+		declared := append(append([]promc.Metric(nil), config.Metrics...), selfMetrics(config)...)
+		relabelings = append(relabelings, monitorRelabel{
+			SourceLabels: []string{"__name__"},
+			Regex:        seriesNameRegex(declared),
+			Action:       "keep",
+		})
+	}
+	if dropDeprecated {
+		if deprecated := deprecatedMetrics(config); len(deprecated) > 0 {
+			relabelings = append(relabelings, monitorRelabel{
+				SourceLabels: []string{"__name__"},
+				Regex:        seriesNameRegex(deprecated),
+				Action:       "drop",
+			})
+		}
+	}
+	return relabelings
+}
+
+// selfMetrics returns the metrics the code generated for config records about
+// itself: the count of unexpected label values if a label restricts its
+// values or a metric is a stateset, and the count of deprecated metric calls
+// if a metric is deprecated.
+func selfMetrics(config *promc.MetricConfig) []promc.Metric {
+	var validatesLabels, hasDeprecated bool
+	for _, metric := range config.Metrics {
+		if metric.Deprecated {
+			hasDeprecated = true
+		}
+		if metric.Type == "stateset" {
+			validatesLabels = true
+		}
+		for _, label := range metric.Labels {
+			if len(label.AllowedValues) > 0 {
+				validatesLabels = true
+			}
+		}
+	}
+
+	var metrics []promc.Metric
+	if validatesLabels {
+		metrics = append(metrics, promc.Metric{Name: "promc_unexpected_label_values_total", Type: "counter"})
+	}
+	if hasDeprecated {
+		metrics = append(metrics, promc.Metric{Name: "promc_deprecated_metric_calls_total", Type: "counter"})
+	}
+	return metrics
+}
+
+// deprecatedMetrics returns the deprecated metrics of config.
+func deprecatedMetrics(config *promc.MetricConfig) []promc.Metric {
+	var deprecated []promc.Metric
+	for _, metric := range config.Metrics {
+		if metric.Deprecated {
+			deprecated = append(deprecated, metric)
+		}
+	}
+	return deprecated
+}
+
+// seriesNameRegex returns a relabeling regex matching the names of every series
+// exposed for metrics, including the _bucket, _sum and _count series of
+// histograms and summaries.
+func seriesNameRegex(metrics []promc.Metric) string {
+	var names []string
+	for _, metric := range metrics {
+		name := prometheus.BuildFQName(metric.Namespace, "", metric.Name)
+		switch metric.Type {
+		case "histogram":
+			name += "(_bucket|_sum|_count)?"
+		case "summary":
+			name += "(_sum|_count)?"
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, "|")
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/remiges-tech/serversage/promc"
+)
+
+// monitorTestConfig has a label with allowed values, a histogram and a
+// deprecated metric.
+var monitorTestConfig = &promc.MetricConfig{Metrics: []promc.Metric{
+	{Name: "orders_total", Type: "counter", Namespace: "shop", Labels: []promc.Label{{Name: "status", AllowedValues: []string{"ok"}}}},
+	{Name: "latency_seconds", Type: "histogram", Namespace: "shop"},
+	{Name: "cart_items", Type: "gauge", Namespace: "shop", Deprecated: true},
+}}
+
+func TestGenerateMonitor(t *testing.T) {
+	defaults := monitorOptions{Kind: "ServiceMonitor", Name: "shop", Port: "metrics", Path: "/metrics", Interval: "30s"}
+
+	tests := []struct {
+		name    string
+		options monitorOptions
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "service monitor",
+			options: defaults,
+			want: `apiVersion: monitoring.coreos.com/v1
+kind: ServiceMonitor
+metadata:
+  name: shop
+spec:
+  selector:
+    matchLabels:
+      app: shop
+  endpoints:
+  - port: metrics
+    path: /metrics
+    interval: 30s
+`,
+		},
+		{
+			name: "pod monitor",
+			options: monitorOptions{
+				Kind:           "PodMonitor",
+				Name:           "shop",
+				Namespace:      "prod",
+				Port:           "web",
+				Path:           "/m",
+				Interval:       "15s",
+				Selector:       map[string]string{"app.kubernetes.io/name": "shop"},
+				Labels:         map[string]string{"release": "prometheus"},
+				TargetLabels:   map[string]string{"team": "checkout", "env": "prod"},
+				DropDeprecated: true,
+				KeepDeclared:   true,
+			},
+			want: `apiVersion: monitoring.coreos.com/v1
+kind: PodMonitor
+metadata:
+  name: shop
+  namespace: prod
+  labels:
+    release: prometheus
+spec:
+  selector:
+    matchLabels:
+      app.kubernetes.io/name: shop
+  podMetricsEndpoints:
+  - port: web
+    path: /m
+    interval: 15s
+    relabelings:
+    - targetLabel: env
+      replacement: prod
+      action: replace
+    - targetLabel: team
+      replacement: checkout
+      action: replace
+    metricRelabelings:
+    - sourceLabels:
+      - __name__
+      regex: promc_deprecated_metric_calls_total|promc_unexpected_label_values_total|shop_cart_items|shop_latency_seconds(_bucket|_sum|_count)?|shop_orders_total
+      action: keep
+    - sourceLabels:
+      - __name__
+      regex: shop_cart_items
+      action: drop
+`,
+		},
+		{
+			name:    "invalid kind",
+			options: monitorOptions{Kind: "Probe", Name: "shop", Interval: "30s"},
+			wantErr: `invalid kind "Probe", must be ServiceMonitor or PodMonitor`,
+		},
+		{
+			name:    "invalid interval",
+			options: monitorOptions{Kind: "ServiceMonitor", Name: "shop", Interval: "soon"},
+			wantErr: `invalid interval: not a valid duration string: "soon"`,
+		},
+		{
+			name:    "invalid target label",
+			options: monitorOptions{Kind: "ServiceMonitor", Name: "shop", Interval: "30s", TargetLabels: map[string]string{"team-name": "checkout"}},
+			wantErr: `invalid target label name "team-name"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := generateMonitor(monitorTestConfig, tt.options)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("generateMonitor() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("generateMonitor() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetricRelabelings(t *testing.T) {
+	counter := promc.Metric{Name: "orders_total", Type: "counter", Namespace: "shop"}
+	summary := promc.Metric{Name: "lookup_seconds", Type: "summary", Namespace: "shop"}
+	restricted := promc.Metric{Name: "payments_total", Type: "counter", Namespace: "shop", Labels: []promc.Label{{Name: "method", AllowedValues: []string{"card"}}}}
+	deprecated := promc.Metric{Name: "cart_items", Type: "gauge", Namespace: "shop", Deprecated: true}
+	stateSet := promc.Metric{Name: "circuit", Type: "stateset", Namespace: "shop", States: []string{"open", "closed"}}
+
+	tests := []struct {
+		name           string
+		metrics        []promc.Metric
+		dropDeprecated bool
+		keepDeclared   bool
+		want           []monitorRelabel
+	}{
+		{
+			name:    "none",
+			metrics: []promc.Metric{counter, deprecated},
+		},
+		{
+			name:         "keep declared",
+			metrics:      []promc.Metric{counter, summary},
+			keepDeclared: true,
+			want: []monitorRelabel{
+				{SourceLabels: []string{"__name__"}, Regex: "shop_lookup_seconds(_sum|_count)?|shop_orders_total", Action: "keep"},
+			},
+		},
+		{
+			name:         "keep unexpected label values",
+			metrics:      []promc.Metric{counter, restricted},
+			keepDeclared: true,
+			want: []monitorRelabel{
+				{SourceLabels: []string{"__name__"}, Regex: "promc_unexpected_label_values_total|shop_orders_total|shop_payments_total", Action: "keep"},
+			},
+		},
+		{
+			name:         "keep unexpected states",
+			metrics:      []promc.Metric{stateSet},
+			keepDeclared: true,
+			want: []monitorRelabel{
+				{SourceLabels: []string{"__name__"}, Regex: "promc_unexpected_label_values_total|shop_circuit", Action: "keep"},
+			},
+		},
+		{
+			name:         "keep deprecated metric calls",
+			metrics:      []promc.Metric{counter, deprecated},
+			keepDeclared: true,
+			want: []monitorRelabel{
+				{SourceLabels: []string{"__name__"}, Regex: "promc_deprecated_metric_calls_total|shop_cart_items|shop_orders_total", Action: "keep"},
+			},
+		},
+		{
+			name:           "drop deprecated",
+			metrics:        []promc.Metric{counter, deprecated},
+			dropDeprecated: true,
+			want: []monitorRelabel{
+				{SourceLabels: []string{"__name__"}, Regex: "shop_cart_items", Action: "drop"},
+			},
+		},
+		{
+			name:           "nothing deprecated to drop",
+			metrics:        []promc.Metric{counter},
+			dropDeprecated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := metricRelabelings(&promc.MetricConfig{Metrics: tt.metrics}, tt.dropDeprecated, tt.keepDeclared)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("metricRelabelings() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/remiges-tech/serversage/promc"
+	"github.com/spf13/cobra"
+)
+
+func newLintCmd() *cobra.Command {
+	var configPath, basePath string
+	var strict bool
+
+	cmd := &cobra.Command{
+		Use:   "lint",
+		Short: "Checks a configuration for problems that schema validation does not catch",
+		Run: func(cmd *cobra.Command, args []string) {
+			config, err := loadConfig(configPath, false)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			problems := collisionProblems(config)
+			problems = append(problems, lintConfig(config, time.Now())...)
+			if strict {
+				problems = append(problems, strictProblems(config)...)
+			}
+			if basePath != "" {
+				base, err := loadConfig(basePath, false)
+				if err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+				problems = append(problems, removalProblems(base, config)...)
+			}
+			for _, problem := range problems {
+				fmt.Printf("- %s\n", problem)
+			}
+			if len(problems) > 0 {
+				fmt.Printf("lint failed: %d problem(s) found\n", len(problems))
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&configPath, "config", "c", "", "Path to the configuration file (required)")
+	cmd.Flags().BoolVar(&strict, "strict", false, "Also apply the checks of strict generation mode")
+	cmd.Flags().StringVar(&basePath, "base", "", "Path to the previous version of the configuration, to check that no stable metric is removed without being deprecated first")
+	cmd.MarkFlagRequired("config")
+
+	return cmd
+}
+
+// lintConfig returns a description of every problem found in config as of now.
+func lintConfig(config *promc.MetricConfig, now time.Time) []string {
+	var problems []string
+	for _, metric := range config.Metrics {
+		for _, label := range metric.Labels {
+			if label.Default != "" && len(label.AllowedValues) > 0 && !contains(label.AllowedValues, label.Default) {
+				problems = append(problems, fmt.Sprintf("%s: default %q of label %s is not an allowed value", metric.Name, label.Default, label.Name))
+			}
+			if label.Default != "" && label.Required {
+				problems = append(problems, fmt.Sprintf("%s: label %s is required but has a default, so it is never empty", metric.Name, label.Name))
+			}
+		}
+		if metric.RemovedAfter == "" {
+			continue
+		}
+		if !metric.Deprecated {
+			problems = append(problems, fmt.Sprintf("%s: removed_after is set but the metric is not deprecated", metric.Name))
+		}
+		removedAfter, err := time.Parse("2006-01-02", metric.RemovedAfter)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: invalid removed_after date: %v", metric.Name, err))
+			continue
+		}
+		if !now.Before(removedAfter.AddDate(0, 0, 1)) {
+			problems = append(problems, fmt.Sprintf("%s: removal date %s has passed, remove the metric", metric.Name, metric.RemovedAfter))
+		}
+	}
+	return problems
+}
+
+// collisionProblems returns every metric whose exposed name collides with
+// another metric, which would make MustRegister panic at runtime, and every
+// Go identifier the generated code would declare twice in the same scope.
+func collisionProblems(config *promc.MetricConfig) []string {
+	var problems []string
+	var metrics []promc.Metric
+	byFullName := make(map[string]promc.Metric)
+	for _, metric := range config.Metrics {
+		fullName := prometheus.BuildFQName(metric.Namespace, "", metric.Name)
+		if other, ok := byFullName[fullName]; ok {
+			switch {
+			case other.Name != metric.Name:
+				problems = append(problems, fmt.Sprintf("%s (%s) and %s (%s) are both exposed as %s after namespace prefixing",
+					other.Name, other.Source, metric.Name, metric.Source, fullName))
+			case !reflect.DeepEqual(labelNames(other.Labels), labelNames(metric.Labels)):
+				problems = append(problems, fmt.Sprintf("%s is defined in %s and %s with different label sets [%s] and [%s]",
+					fullName, other.Source, metric.Source, strings.Join(labelNames(other.Labels), ", "), strings.Join(labelNames(metric.Labels), ", ")))
+			default:
+				problems = append(problems, fmt.Sprintf("%s is defined in both %s and %s", fullName, other.Source, metric.Source))
+			}
+			continue
+		}
+		byFullName[fullName] = metric
+		metrics = append(metrics, metric)
+	}
+
+	packageScope, metricsScope := generatedIdentifiers(config, metrics)
+	problems = append(problems, identifierProblems(packageScope)...)
+	problems = append(problems, identifierProblems(metricsScope)...)
+	return problems
+}
+
+// generatedIdentifier is a Go identifier declared by the generated code,
+// together with a description of what it is generated for.
+type generatedIdentifier struct {
+	name   string
+	origin string
+}
+
+// generatedIdentifiers returns the exported identifiers the code generated
+// for metrics declares in the package scope and, with the struct API, as
+// fields and methods of Metrics.
+func generatedIdentifiers(config *promc.MetricConfig, metrics []promc.Metric) (packageScope, metricsScope []generatedIdentifier) {
+	structAPI := config.API == "struct"
+	fixed := func(scope *[]generatedIdentifier, name, kind string) {
+		*scope = append(*scope, generatedIdentifier{name: name, origin: fmt.Sprintf("the generated %s %s", name, kind)})
+	}
+
+	// Metrics and their record functions are package-level with the
+	// functions API and members of Metrics with the struct API.
+	members := &packageScope
+	if structAPI {
+		members = &metricsScope
+	}
+	var exemplars, stability bool
+	labelOrigins := make(map[string]string)
+	var labels []string
+	for _, metric := range metrics {
+		name := promc.SnakeToCamel(metric.Name)
+		origin := fmt.Sprintf("%s (%s)", prometheus.BuildFQName(metric.Namespace, "", metric.Name), metric.Source)
+		record := "Record" + name
+		if metric.Type == "info" || metric.Type == "stateset" {
+			record = "Set" + name
+		}
+		*members = append(*members, generatedIdentifier{name: name, origin: origin}, generatedIdentifier{name: record, origin: origin})
+		if wrapper := metric.UnitWrapper(); wrapper != "" {
+			*members = append(*members, generatedIdentifier{name: record + wrapper, origin: origin})
+		}
+		if metric.Type == "stateset" {
+			packageScope = append(packageScope, generatedIdentifier{name: name + "Value", origin: origin})
+			for _, state := range metric.States {
+				packageScope = append(packageScope, generatedIdentifier{name: name + promc.SnakeToCamel(state), origin: "state " + state + " of " + origin})
+			}
+		}
+		for _, label := range metric.Labels {
+			if _, ok := labelOrigins[label.Name]; !ok {
+				labelOrigins[label.Name] = "label " + label.Name
+				labels = append(labels, label.Name)
+			}
+		}
+		exemplars = exemplars || metric.Exemplars
+		stability = stability || metric.Stability != ""
+	}
+	for _, label := range labels {
+		packageScope = append(packageScope, generatedIdentifier{name: promc.SnakeToCamel(label), origin: labelOrigins[label]})
+	}
+
+	if structAPI {
+		fixed(&packageScope, "Metrics", "type")
+		fixed(&packageScope, "NewMetrics", "function")
+		if exemplars {
+			fixed(&metricsScope, "ExemplarFromContext", "field")
+		}
+		switch config.DI {
+		case "wire":
+			fixed(&packageScope, "ProviderSet", "variable")
+		case "fx":
+			fixed(&packageScope, "Module", "variable")
+		}
+	} else if exemplars {
+		fixed(&packageScope, "ExemplarFromContext", "variable")
+	}
+	if stability {
+		fixed(&packageScope, "MetricStability", "variable")
+	}
+	if config.Interface {
+		fixed(&packageScope, "MetricsRecorder", "interface")
+		fixed(&packageScope, "NopMetricsRecorder", "type")
+		if !structAPI {
+			fixed(&packageScope, "Recorder", "variable")
+		}
+	}
+	if config.GRPC != nil {
+		kind := "function"
+		if structAPI {
+			kind = "method"
+		}
+		if config.GRPC.Server != nil {
+			fixed(members, "UnaryServerInterceptor", kind)
+			fixed(members, "StreamServerInterceptor", kind)
+		}
+		if config.GRPC.Client != nil {
+			fixed(members, "UnaryClientInterceptor", kind)
+			fixed(members, "StreamClientInterceptor", kind)
+		}
+	}
+	return packageScope, metricsScope
+}
+
+// identifierProblems returns a problem for every identifier declared more
+// than once in the scope identifiers belong to, reporting every pair of
+// clashing origins once.
+func identifierProblems(identifiers []generatedIdentifier) []string {
+	var problems []string
+	byName := make(map[string]generatedIdentifier)
+	reported := make(map[[2]string]bool)
+	for _, identifier := range identifiers {
+		other, ok := byName[identifier.name]
+		if !ok {
+			byName[identifier.name] = identifier
+			continue
+		}
+		pair := [2]string{other.origin, identifier.origin}
+		if reported[pair] {
+			continue
+		}
+		reported[pair] = true
+		problems = append(problems, fmt.Sprintf("%s and %s both generate the Go identifier %s", other.origin, identifier.origin, identifier.name))
+	}
+	return problems
+}
+
+// removalProblems returns a problem for every stable metric of base that was
+// removed from config without being deprecated in base first.
+func removalProblems(base, config *promc.MetricConfig) []string {
+	var problems []string
+	for _, metric := range base.Metrics {
+		if metric.Stability != "stable" || metric.Deprecated {
+			continue
+		}
+		removed := true
+		for _, other := range config.Metrics {
+			if other.Name == metric.Name {
+				removed = false
+				break
+			}
+		}
+		if removed {
+			problems = append(problems, fmt.Sprintf("%s: stable metric removed without being deprecated first", metric.Name))
+		}
+	}
+	return problems
+}
+
+// strictProblems returns the violations of the help text and naming
+// conventions enforced in strict mode.
+func strictProblems(config *promc.MetricConfig) []string {
+	var problems []string
+	for _, metric := range config.Metrics {
+		if strings.TrimSpace(metric.Help) == "" {
+			problems = append(problems, fmt.Sprintf("%s: help text is missing", metric.Name))
+		}
+
+		name := metric.Name
+		if metric.Type == "counter" {
+			if !strings.HasSuffix(name, "_total") {
+				problems = append(problems, fmt.Sprintf("%s: counter name must end in _total", metric.Name))
+			}
+			name = strings.TrimSuffix(name, "_total")
+		}
+		name = strings.TrimSuffix(name, "_"+metric.Unit)
+		for _, unit := range promc.Units {
+			if metric.Unit != "" && strings.HasSuffix(name, "_"+unit) {
+				problems = append(problems, fmt.Sprintf("%s: name ends in _%s but the unit is %s", metric.Name, unit, metric.Unit))
+			}
+		}
+	}
+	return problems
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/remiges-tech/serversage/promc"
+)
+
+func TestLintConfig(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		metric promc.Metric
+		want   []string
+	}{
+		{
+			name:   "no problems",
+			metric: promc.Metric{Name: "orders_total", Labels: []promc.Label{{Name: "status", AllowedValues: []string{"ok", "failed"}, Default: "ok"}}},
+		},
+		{
+			name:   "default not allowed",
+			metric: promc.Metric{Name: "orders_total", Labels: []promc.Label{{Name: "status", AllowedValues: []string{"ok", "failed"}, Default: "unknown"}}},
+			want:   []string{`orders_total: default "unknown" of label status is not an allowed value`},
+		},
+		{
+			name:   "default without allowed values",
+			metric: promc.Metric{Name: "orders_total", Labels: []promc.Label{{Name: "status", Default: "unknown"}}},
+		},
+		{
+			name:   "required label with default",
+			metric: promc.Metric{Name: "orders_total", Labels: []promc.Label{{Name: "status", Default: "ok", Required: true}}},
+			want:   []string{"orders_total: label status is required but has a default, so it is never empty"},
+		},
+		{
+			name:   "required label",
+			metric: promc.Metric{Name: "orders_total", Labels: []promc.Label{{Name: "status", Required: true}}},
+		},
+		{
+			name:   "removed_after without deprecation",
+			metric: promc.Metric{Name: "orders_total", RemovedAfter: "2024-12-31"},
+			want:   []string{"orders_total: removed_after is set but the metric is not deprecated"},
+		},
+		{
+			name:   "invalid removed_after",
+			metric: promc.Metric{Name: "orders_total", Deprecated: true, RemovedAfter: "31.12.2024"},
+			want:   []string{`orders_total: invalid removed_after date: parsing time "31.12.2024" as "2006-01-02": cannot parse "31.12.2024" as "2006"`},
+		},
+		{
+			name:   "removal date ahead",
+			metric: promc.Metric{Name: "orders_total", Deprecated: true, RemovedAfter: "2024-12-31"},
+		},
+		{
+			name:   "removal date today",
+			metric: promc.Metric{Name: "orders_total", Deprecated: true, RemovedAfter: "2024-06-15"},
+		},
+		{
+			name:   "removal date passed",
+			metric: promc.Metric{Name: "orders_total", Deprecated: true, RemovedAfter: "2024-06-14"},
+			want:   []string{"orders_total: removal date 2024-06-14 has passed, remove the metric"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := lintConfig(&promc.MetricConfig{Metrics: []promc.Metric{tt.metric}}, now)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lintConfig() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemovalProblems(t *testing.T) {
+	stable := promc.Metric{Name: "orders_total", Stability: "stable"}
+	deprecated := promc.Metric{Name: "orders_total", Stability: "stable", Deprecated: true}
+	beta := promc.Metric{Name: "orders_total", Stability: "beta"}
+
+	tests := []struct {
+		name   string
+		base   []promc.Metric
+		config []promc.Metric
+		want   []string
+	}{
+		{
+			name:   "stable metric kept",
+			base:   []promc.Metric{stable},
+			config: []promc.Metric{stable},
+		},
+		{
+			name: "stable metric removed",
+			base: []promc.Metric{stable},
+			want: []string{"orders_total: stable metric removed without being deprecated first"},
+		},
+		{
+			name: "deprecated stable metric removed",
+			base: []promc.Metric{deprecated},
+		},
+		{
+			name: "beta metric removed",
+			base: []promc.Metric{beta},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := removalProblems(&promc.MetricConfig{Metrics: tt.base}, &promc.MetricConfig{Metrics: tt.config})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("removalProblems() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollisionProblems(t *testing.T) {
+	gauge := func(name string) promc.Metric {
+		return promc.Metric{Name: name, Type: "gauge", Namespace: "shop", Source: "a.json"}
+	}
+	withLabel := func(metric promc.Metric, label string) promc.Metric {
+		metric.Labels = []promc.Label{{Name: label}}
+		return metric
+	}
+	stateSet := promc.Metric{Name: "circuit", Type: "stateset", Namespace: "shop", Source: "a.json", States: []string{"open", "closed"}}
+
+	tests := []struct {
+		name   string
+		config promc.MetricConfig
+		want   []string
+	}{
+		{
+			name:   "no collisions",
+			config: promc.MetricConfig{Interface: true, Metrics: []promc.Metric{withLabel(gauge("orders"), "status"), stateSet}},
+		},
+		{
+			name: "metric defined twice",
+			config: promc.MetricConfig{Metrics: []promc.Metric{gauge("orders"), func() promc.Metric {
+				m := gauge("orders")
+				m.Source = "b.json"
+				return m
+			}()}},
+			want: []string{"shop_orders is defined in both a.json and b.json"},
+		},
+		{
+			name:   "metric identifiers",
+			config: promc.MetricConfig{Metrics: []promc.Metric{gauge("cache_hits"), gauge("cache__hits")}},
+			want:   []string{"shop_cache_hits (a.json) and shop_cache__hits (a.json) both generate the Go identifier CacheHits"},
+		},
+		{
+			name:   "metric and record function",
+			config: promc.MetricConfig{Metrics: []promc.Metric{gauge("orders"), gauge("record_orders")}},
+			want:   []string{"shop_orders (a.json) and shop_record_orders (a.json) both generate the Go identifier RecordOrders"},
+		},
+		{
+			name:   "metric and label type",
+			config: promc.MetricConfig{Metrics: []promc.Metric{gauge("status"), withLabel(gauge("orders"), "status")}},
+			want:   []string{"shop_status (a.json) and label status both generate the Go identifier Status"},
+		},
+		{
+			name:   "metric and state",
+			config: promc.MetricConfig{Metrics: []promc.Metric{stateSet, gauge("circuit_open")}},
+			want:   []string{"state open of shop_circuit (a.json) and shop_circuit_open (a.json) both generate the Go identifier CircuitOpen"},
+		},
+		{
+			name:   "metric and recorder interface",
+			config: promc.MetricConfig{Interface: true, Metrics: []promc.Metric{gauge("metrics_recorder"), gauge("nop_metrics_recorder"), gauge("recorder")}},
+			want: []string{
+				"shop_metrics_recorder (a.json) and the generated MetricsRecorder interface both generate the Go identifier MetricsRecorder",
+				"shop_nop_metrics_recorder (a.json) and the generated NopMetricsRecorder type both generate the Go identifier NopMetricsRecorder",
+				"shop_recorder (a.json) and the generated Recorder variable both generate the Go identifier Recorder",
+			},
+		},
+		{
+			name: "metric and exemplar hook",
+			config: promc.MetricConfig{Metrics: []promc.Metric{
+				{Name: "exemplar_from_context", Type: "counter", Namespace: "shop", Source: "a.json", Exemplars: true},
+			}},
+			want: []string{"shop_exemplar_from_context (a.json) and the generated ExemplarFromContext variable both generate the Go identifier ExemplarFromContext"},
+		},
+		{
+			name:   "struct API metrics are fields",
+			config: promc.MetricConfig{API: "struct", Interface: true, Metrics: []promc.Metric{gauge("metrics_recorder"), gauge("new_metrics")}},
+		},
+		{
+			name:   "struct API label types",
+			config: promc.MetricConfig{API: "struct", Metrics: []promc.Metric{withLabel(gauge("orders"), "metrics"), withLabel(gauge("sales"), "new_metrics")}},
+			want: []string{
+				"label metrics and the generated Metrics type both generate the Go identifier Metrics",
+				"label new_metrics and the generated NewMetrics function both generate the Go identifier NewMetrics",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := collisionProblems(&tt.config)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("collisionProblems() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,47 +3,25 @@
 package main
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
-	"go/format"
 	"os"
+	"path/filepath"
 	"strings"
-	"text/template"
 
+	"github.com/remiges-tech/serversage/promc"
 	"github.com/spf13/cobra"
 	"github.com/xeipuuv/gojsonschema"
-	"golang.org/x/text/cases"
-	"golang.org/x/text/language"
 )
 
-// MetricConfig represents the YAML configuration file structure.
-type MetricConfig struct {
-	Metrics      []Metric        `yaml:"metrics"`
-	PackageName  string          `yaml:"package_name"`
-	UniqueLabels map[string]bool `yaml:"-"`
-}
-
-type Metric struct {
-	Name    string    `yaml:"name"`
-	Type    string    `yaml:"type"`
-	Labels  []string  `yaml:"labels,omitempty"`
-	Help    string    `yaml:"help,omitempty"`
-	Buckets []float64 `yaml:"buckets,omitempty"`
-}
-
-// Convert snake_case to CamelCase
-func snakeToCamel(s string) string {
-	parts := strings.Split(s, "_")
-	c := cases.Title(language.English)
-	for i, part := range parts {
-		parts[i] = c.String(part)
-	}
-	return strings.Join(parts, "")
-}
+// currentSchemaVersion is the newest configuration schema version promc understands.
+const currentSchemaVersion = 2
 
 func main() {
-	var configPath, outputPath, packageName string
+	var configPaths []string
+	var outputPath, packageName, api, di, buildTags, headerPath string
+	var importAliases map[string]string
+	var strict, strictEnv, withTests, withInterface bool
 
 	var rootCmd = &cobra.Command{
 		Use:   "generate",
@@ -51,63 +29,62 @@ func main() {
 		Long: `A tool to generate Prometheus metrics Go code from a JSON configuration file.
 Complete documentation is available at http://example.com`,
 		Run: func(cmd *cobra.Command, args []string) {
-			// Load and parse the YAML configuration file.
-			content, err := os.ReadFile(configPath)
+			config, err := loadConfigs(configPaths, strictEnv)
 			if err != nil {
-				fmt.Printf("error reading config file: %v\n", err)
+				fmt.Println(err)
 				os.Exit(1)
 			}
 
-			// Validate the JSON config
-			err = validateConfig(content)
-			if err != nil {
-				fmt.Printf("config validation failed: %v\n", err)
-				os.Exit(1)
+			if strict {
+				problems := strictProblems(config)
+				if len(problems) > 0 {
+					fmt.Printf("strict mode check failed:\n- %s\n", strings.Join(problems, "\n- "))
+					os.Exit(1)
+				}
 			}
 
-			var config MetricConfig
-			err = json.Unmarshal(content, &config)
-			if err != nil {
-				fmt.Printf("error parsing config file: %v\n", err)
-				os.Exit(1)
+			// Command line output settings take precedence over the config.
+			if api != "" {
+				config.API = api
 			}
-
-			// Populate unique labels
-			config.UniqueLabels = make(map[string]bool)
-			for _, metric := range config.Metrics {
-				for _, label := range metric.Labels {
-					config.UniqueLabels[label] = true
+			if buildTags != "" {
+				config.BuildTags = buildTags
+			}
+			if withInterface {
+				config.Interface = true
+			}
+			if di != "" {
+				config.DI = di
+			}
+			if headerPath != "" {
+				header, err := os.ReadFile(headerPath)
+				if err != nil {
+					fmt.Printf("error reading header file: %v\n", err)
+					os.Exit(1)
 				}
+				config.Header = string(header)
 			}
-
-			// Define a custom function map
-			funcMap := template.FuncMap{
-				"snakeToCamel": snakeToCamel,
+			if len(importAliases) > 0 && config.ImportAliases == nil {
+				config.ImportAliases = make(map[string]string)
 			}
-
-			// Generate Go code from the template with the custom function map.
-			t, err := template.New("metrics").Funcs(funcMap).Parse(metricsTemplate)
-			if err != nil {
-				fmt.Printf("error parsing template: %v\n", err)
-				os.Exit(1)
+			for path, alias := range importAliases {
+				config.ImportAliases[path] = alias
 			}
 
-			// Create a buffer to hold the executed template before formatting.
-			var buf bytes.Buffer
-
 			// Set package name in the config passed for template execution
 			config.PackageName = packageName
 
-			err = t.Execute(&buf, config)
-			if err != nil {
-				fmt.Printf("error executing template: %v\n", err)
+			// Report every metric that would panic in MustRegister or fail to
+			// compile instead of stopping at the first one. The output settings
+			// decide which identifiers are generated, so this runs after them.
+			if problems := collisionProblems(config); len(problems) > 0 {
+				fmt.Printf("metric collisions found:\n- %s\n", strings.Join(problems, "\n- "))
 				os.Exit(1)
 			}
 
-			// Format the source code in the buffer.
-			formattedSource, err := format.Source(buf.Bytes())
+			formattedSource, err := promc.Generate(config)
 			if err != nil {
-				fmt.Printf("error formatting source: %v\n", err)
+				fmt.Println(err)
 				os.Exit(1)
 			}
 
@@ -125,13 +102,38 @@ Complete documentation is available at http://example.com`,
 				fmt.Printf("error writing to output file: %v\n", err)
 				os.Exit(1)
 			}
+
+			if withTests {
+				testSource, err := promc.GenerateTests(config)
+				if err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+
+				testPath := strings.TrimSuffix(outputPath, ".go") + "_test.go"
+				err = os.WriteFile(testPath, testSource, 0o644)
+				if err != nil {
+					fmt.Printf("error writing test file: %v\n", err)
+					os.Exit(1)
+				}
+			}
 		},
 	}
 
-	rootCmd.Flags().StringVarP(&configPath, "config", "c", "", "Path to the configuration file (required)")
+	rootCmd.Flags().StringArrayVarP(&configPaths, "config", "c", nil, "Path to the configuration file, may be repeated to merge several files (required)")
 	rootCmd.Flags().StringVarP(&outputPath, "output", "o", "", "Path to the output file (required)")
 	rootCmd.Flags().StringVarP(&packageName, "package", "p", "", "Package name for the output file (required)")
 
+	rootCmd.Flags().StringVar(&api, "api", "", "Style of the generated API: functions (default) or struct")
+	rootCmd.Flags().BoolVar(&withInterface, "interface", false, "Also generate a MetricsRecorder interface with a no-op implementation")
+	rootCmd.Flags().StringVar(&di, "di", "", "Dependency injection framework to generate providers for with the struct API: wire or fx")
+	rootCmd.Flags().StringVar(&buildTags, "build-tags", "", "Build constraint expression for the output file, e.g. '!nometrics'")
+	rootCmd.Flags().StringVar(&headerPath, "header-file", "", "Path to a file whose contents are added as a comment at the top of the output file")
+	rootCmd.Flags().StringToStringVar(&importAliases, "import-alias", nil, "Import alias for a package imported by the output file, as path=alias")
+	rootCmd.Flags().BoolVar(&withTests, "with-tests", false, "Also generate a _test.go file that smoke-tests every generated metric")
+	rootCmd.Flags().BoolVar(&strict, "strict", false, "Fail if a metric lacks help text or its name does not follow the naming conventions")
+	rootCmd.Flags().BoolVar(&strictEnv, "strict-env", false, "Fail if the config references an unset environment variable")
+
 	rootCmd.MarkFlagRequired("config")
 	rootCmd.MarkFlagRequired("output")
 	rootCmd.MarkFlagRequired("package")
@@ -144,6 +146,16 @@ Complete documentation is available at http://example.com`,
 		},
 	}
 	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(newLintCmd())
+	rootCmd.AddCommand(newDiffCmd())
+	rootCmd.AddCommand(newTestCmd())
+	rootCmd.AddCommand(newK8sCmd())
+	rootCmd.AddCommand(newScrapeConfigCmd())
+	rootCmd.AddCommand(newCheckCmd())
+	rootCmd.AddCommand(newFmtCmd())
+	rootCmd.AddCommand(newMigrateCmd())
+	rootCmd.AddCommand(newAddCmd())
+	rootCmd.AddCommand(newConstantsCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
@@ -151,6 +163,115 @@ Complete documentation is available at http://example.com`,
 	}
 }
 
+// loadConfig reads the configuration file at path together with the files it
+// includes, validates them against the schema and resolves environment
+// variable references in their values.
+func loadConfig(path string, strictEnv bool) (*promc.MetricConfig, error) {
+	return loadConfigs([]string{path}, strictEnv)
+}
+
+// loadConfigs loads every configuration file in paths and the files they
+// include, and merges their metrics into a single config. The remaining
+// settings are taken from the first file. A file that is included more than
+// once is only loaded the first time.
+func loadConfigs(paths []string, strictEnv bool) (*promc.MetricConfig, error) {
+	return loadConfigsEnv(paths, strictEnv, os.LookupEnv)
+}
+
+// loadConfigsEnv is loadConfigs with environment variables looked up with
+// lookup instead of in the process environment.
+func loadConfigsEnv(paths []string, strictEnv bool, lookup func(string) (string, bool)) (*promc.MetricConfig, error) {
+	var merged *promc.MetricConfig
+	loaded := make(map[string]bool)
+
+	var load func(path string) error
+	load = func(path string) error {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if loaded[absPath] {
+			return nil
+		}
+		loaded[absPath] = true
+
+		config, err := readConfigFile(path, strictEnv, lookup)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if merged == nil {
+			first := *config
+			first.Metrics = nil
+			merged = &first
+		}
+		merged.Metrics = append(merged.Metrics, config.Metrics...)
+
+		for _, include := range config.Includes {
+			if !filepath.IsAbs(include) {
+				include = filepath.Join(filepath.Dir(path), include)
+			}
+			if err := load(include); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, path := range paths {
+		if err := load(path); err != nil {
+			return nil, err
+		}
+	}
+	return merged, nil
+}
+
+// readConfigFile reads a single configuration file, validates it against the
+// schema and resolves environment variable references in its values.
+func readConfigFile(path string, strictEnv bool, lookup func(string) (string, bool)) (*promc.MetricConfig, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %v", err)
+	}
+	document, err := decodeConfigDocument(content, isYAMLPath(path))
+	if err != nil {
+		return nil, err
+	}
+
+	// Older schema versions are upgraded in memory, so the rest of promc only
+	// deals with the current one.
+	version := documentSchemaVersion(document)
+	err = migrateDocument(document, currentSchemaVersion)
+	if err != nil {
+		return nil, err
+	}
+	content, err = json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+
+	var config promc.MetricConfig
+	err = json.Unmarshal(content, &config)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing config file: %v", err)
+	}
+	config.SchemaVersion = version
+
+	// Resolve ${ENV_VAR} references in the config values.
+	err = expandConfigEnv(&config, strictEnv, lookup)
+	if err != nil {
+		return nil, fmt.Errorf("error expanding environment variables: %v", err)
+	}
+
+	for i := range config.Metrics {
+		config.Metrics[i].ConfiguredName = config.Metrics[i].Name
+		config.Metrics[i].Name = promc.SuffixedName(config.Metrics[i])
+		config.Metrics[i].Namespace = config.Namespace
+		config.Metrics[i].Source = path
+	}
+
+	return &config, nil
+}
+
 func validateConfig(content []byte) error {
 	// Load the JSON schema
 	schemaLoader := gojsonschema.NewStringLoader(metricConfigSchema)
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// migrations upgrade a decoded configuration by one schema version: the
+// migration at index i turns version i+1 into version i+2.
+var migrations = []func(document map[string]interface{}){
+	migrateDescriptionToHelp,
+}
+
+// migrateDescriptionToHelp renames the description of every metric to help,
+// the field the generated code has always used. An existing help text takes
+// precedence over the description.
+func migrateDescriptionToHelp(document map[string]interface{}) {
+	metrics, _ := document["metrics"].([]interface{})
+	for _, metric := range metrics {
+		metric := metric.(map[string]interface{})
+		description, ok := metric["description"]
+		if !ok {
+			continue
+		}
+		if _, ok := metric["help"]; !ok {
+			metric["help"] = description
+		}
+		delete(metric, "description")
+	}
+}
+
+func newMigrateCmd() *cobra.Command {
+	var write bool
+	var toVersion int
+
+	cmd := &cobra.Command{
+		Use:   "migrate CONFIG...",
+		Short: "Upgrades configuration files to a newer schema version",
+		Long: `Rewrites configuration files for a newer schema version, e.g. renaming the
+description of metrics to help for version 2. The result is printed to standard
+output in canonical form unless -w is given.`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			for _, path := range args {
+				content, err := os.ReadFile(path)
+				if err != nil {
+					fmt.Printf("error reading config file: %v\n", err)
+					os.Exit(1)
+				}
+				document, err := decodeConfigDocument(content, isYAMLPath(path))
+				if err == nil {
+					err = migrateDocument(document, toVersion)
+				}
+				if err != nil {
+					fmt.Printf("%s: %v\n", path, err)
+					os.Exit(1)
+				}
+				migrated, err := encodeConfigDocument(document, isYAMLPath(path))
+				if err != nil {
+					fmt.Printf("%s: %v\n", path, err)
+					os.Exit(1)
+				}
+
+				if !write {
+					os.Stdout.Write(migrated)
+					continue
+				}
+				err = os.WriteFile(path, migrated, 0o644)
+				if err != nil {
+					fmt.Printf("error writing output file: %v\n", err)
+					os.Exit(1)
+				}
+			}
+		},
+	}
+
+	cmd.Flags().BoolVarP(&write, "write", "w", false, "Write the result to the file instead of standard output")
+	cmd.Flags().IntVar(&toVersion, "to-version", currentSchemaVersion, "Schema version to upgrade to")
+
+	return cmd
+}
+
+// documentSchemaVersion returns the schema version of a decoded configuration.
+func documentSchemaVersion(document map[string]interface{}) int {
+	if version, ok := document["schema_version"].(float64); ok {
+		return int(version)
+	}
+	return 1
+}
+
+// checkSchemaVersion returns an error if a decoded configuration is newer
+// than promc supports, or uses fields removed in its schema version.
+func checkSchemaVersion(document map[string]interface{}) error {
+	version := documentSchemaVersion(document)
+	if version > currentSchemaVersion {
+		return fmt.Errorf("config schema version %d is newer than the supported version %d, upgrade promc", version, currentSchemaVersion)
+	}
+	if version >= 2 {
+		metrics, _ := document["metrics"].([]interface{})
+		for _, metric := range metrics {
+			metric := metric.(map[string]interface{})
+			if _, ok := metric["description"]; ok {
+				return fmt.Errorf("%v: description was renamed to help in schema version 2", metric["name"])
+			}
+		}
+	}
+	return nil
+}
+
+// migrateDocument upgrades a decoded configuration to schema version to.
+func migrateDocument(document map[string]interface{}, to int) error {
+	if err := checkSchemaVersion(document); err != nil {
+		return err
+	}
+	from := documentSchemaVersion(document)
+	if to < from || to > currentSchemaVersion {
+		return fmt.Errorf("cannot migrate from schema version %d to %d", from, to)
+	}
+	for version := from; version < to; version++ {
+		migrations[version-1](document)
+	}
+	if to > from {
+		document["schema_version"] = to
+	}
+	return nil
+}
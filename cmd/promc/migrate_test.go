@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMigrateDocument(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		to       int
+		want     string
+		wantErr  string
+	}{
+		{
+			name:     "version 1",
+			document: `{"metrics":[{"name":"a_total","type":"counter","description":"A."},{"name":"b_total","type":"counter","description":"B.","help":"Help of B."},{"name":"c_total","type":"counter"}]}`,
+			to:       2,
+			want:     `{"schema_version":2,"metrics":[{"name":"a_total","type":"counter","help":"A."},{"name":"b_total","type":"counter","help":"Help of B."},{"name":"c_total","type":"counter"}]}`,
+		},
+		{
+			name:     "version 1 kept",
+			document: `{"metrics":[{"name":"a_total","type":"counter","description":"A."}]}`,
+			to:       1,
+			want:     `{"metrics":[{"name":"a_total","type":"counter","description":"A."}]}`,
+		},
+		{
+			name:     "current version",
+			document: `{"schema_version":2,"metrics":[{"name":"a_total","type":"counter","help":"A."}]}`,
+			to:       currentSchemaVersion,
+			want:     `{"schema_version":2,"metrics":[{"name":"a_total","type":"counter","help":"A."}]}`,
+		},
+		{
+			name:     "future version",
+			document: `{"schema_version":3,"metrics":[]}`,
+			to:       currentSchemaVersion,
+			wantErr:  "config schema version 3 is newer than the supported version 2, upgrade promc",
+		},
+		{
+			name:     "downgrade",
+			document: `{"schema_version":2,"metrics":[]}`,
+			to:       1,
+			wantErr:  "cannot migrate from schema version 2 to 1",
+		},
+		{
+			name:     "removed field",
+			document: `{"schema_version":2,"metrics":[{"name":"a_total","type":"counter","description":"A."}]}`,
+			to:       currentSchemaVersion,
+			wantErr:  "a_total: description was renamed to help in schema version 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var document map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.document), &document); err != nil {
+				t.Fatal(err)
+			}
+
+			err := migrateDocument(document, tt.to)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("migrateDocument() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := normalizeJSON(t, document), normalizeJSON(t, tt.want); got != want {
+				t.Errorf("migrateDocument() = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+// normalizeJSON returns v, or the document v holds if it is a string, encoded
+// as JSON with sorted keys.
+func normalizeJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	if text, ok := v.(string); ok {
+		if err := json.Unmarshal([]byte(text), &v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(encoded)
+}
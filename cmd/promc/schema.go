@@ -5,6 +5,55 @@ const metricConfigSchema = `
   "$schema": "http://json-schema.org/draft-07/schema#",
   "type": "object",
   "properties": {
+    "schema_version": {
+      "type": "integer",
+      "minimum": 1
+    },
+    "namespace": {
+      "type": "string"
+    },
+    "api": {
+      "type": "string",
+      "enum": ["functions", "struct"]
+    },
+    "interface": {
+      "type": "boolean"
+    },
+    "di": {
+      "type": "string",
+      "enum": ["wire", "fx"]
+    },
+    "build_tags": {
+      "type": "string"
+    },
+    "header": {
+      "type": "string"
+    },
+    "import_aliases": {
+      "type": "object",
+      "additionalProperties": {
+        "type": "string"
+      }
+    },
+    "grpc": {
+      "type": "object",
+      "properties": {
+        "server": {
+          "$ref": "#/definitions/grpcMetrics"
+        },
+        "client": {
+          "$ref": "#/definitions/grpcMetrics"
+        }
+      },
+      "minProperties": 1,
+      "additionalProperties": false
+    },
+    "includes": {
+      "type": "array",
+      "items": {
+        "type": "string"
+      }
+    },
     "metrics": {
       "type": "array",
       "items": {
@@ -15,7 +64,7 @@ const metricConfigSchema = `
           },
           "type": {
             "type": "string",
-            "enum": ["counter", "gauge", "histogram", "summary"]
+            "enum": ["counter", "gauge", "histogram", "summary", "info", "stateset"]
           },
           "description": {
             "type": "string"
@@ -23,17 +72,107 @@ const metricConfigSchema = `
           "help": {
             "type": "string"
           },
+          "unit": {
+            "type": "string",
+            "enum": ["seconds", "milliseconds", "bytes", "ratio"]
+          },
           "labels": {
             "type": "array",
             "items": {
+              "oneOf": [
+                {
+                  "type": "string"
+                },
+                {
+                  "type": "object",
+                  "properties": {
+                    "name": {
+                      "type": "string"
+                    },
+                    "allowed_values": {
+                      "type": "array",
+                      "items": {
+                        "type": "string"
+                      },
+                      "minItems": 1
+                    },
+                    "on_unexpected": {
+                      "type": "string",
+                      "enum": ["other", "reject"]
+                    },
+                    "default": {
+                      "type": "string",
+                      "minLength": 1
+                    },
+                    "required": {
+                      "type": "boolean"
+                    }
+                  },
+                  "required": ["name"],
+                  "additionalProperties": false
+                }
+              ]
+            }
+          },
+          "const_labels": {
+            "type": "object",
+            "additionalProperties": {
               "type": "string"
             }
           },
           "buckets": {
+            "oneOf": [
+              {
+                "type": "array",
+                "items": {
+                  "type": "number"
+                }
+              },
+              {
+                "type": "string"
+              }
+            ]
+          },
+          "objectives": {
+            "type": "object",
+            "propertyNames": {
+              "pattern": "^(0(\\.[0-9]+)?|1(\\.0+)?)$"
+            },
+            "additionalProperties": {
+              "type": "number",
+              "minimum": 0,
+              "exclusiveMaximum": 1
+            }
+          },
+          "max_age": {
+            "type": "string"
+          },
+          "age_buckets": {
+            "type": "integer",
+            "minimum": 1
+          },
+          "exemplars": {
+            "type": "boolean"
+          },
+          "deprecated": {
+            "type": "boolean"
+          },
+          "removed_after": {
+            "type": "string",
+            "pattern": "^[0-9]{4}-[0-9]{2}-[0-9]{2}$"
+          },
+          "stability": {
+            "type": "string",
+            "enum": ["alpha", "beta", "stable"]
+          },
+          "states": {
             "type": "array",
             "items": {
-              "type": "number"
-            }
+              "type": "string",
+              "pattern": "^[a-zA-Z_][a-zA-Z0-9_]*$"
+            },
+            "minItems": 1,
+            "uniqueItems": true
           }
         },
         "required": ["name", "type"],
@@ -49,7 +188,7 @@ const metricConfigSchema = `
             "then": {
               "properties": {
                 "buckets": {
-                  "type": "array",
+                  "type": ["array", "string"],
                   "items": {
                     "type": "number"
                   }
@@ -63,12 +202,102 @@ const metricConfigSchema = `
                 }
               }
             }
+          },
+          {
+            "if": {
+              "properties": {
+                "type": {
+                  "const": "summary"
+                }
+              }
+            },
+            "else": {
+              "properties": {
+                "objectives": {
+                  "type": "null"
+                },
+                "max_age": {
+                  "type": "null"
+                },
+                "age_buckets": {
+                  "type": "null"
+                }
+              }
+            }
+          },
+          {
+            "if": {
+              "properties": {
+                "type": {
+                  "enum": ["gauge", "summary", "info", "stateset"]
+                }
+              }
+            },
+            "then": {
+              "properties": {
+                "exemplars": {
+                  "const": false
+                }
+              }
+            }
+          },
+          {
+            "if": {
+              "properties": {
+                "type": {
+                  "enum": ["info", "stateset"]
+                }
+              }
+            },
+            "then": {
+              "properties": {
+                "unit": {
+                  "type": "null"
+                }
+              }
+            }
+          },
+          {
+            "if": {
+              "properties": {
+                "type": {
+                  "const": "stateset"
+                }
+              }
+            },
+            "then": {
+              "required": ["states"]
+            },
+            "else": {
+              "properties": {
+                "states": {
+                  "type": "null"
+                }
+              }
+            }
           }
         ],
         "additionalProperties": false
       }
     }
   },
-  "required": ["metrics"]
+  "required": ["metrics"],
+  "definitions": {
+    "grpcMetrics": {
+      "type": "object",
+      "properties": {
+        "requests_metric": {
+          "type": "string",
+          "minLength": 1
+        },
+        "latency_metric": {
+          "type": "string",
+          "minLength": 1
+        }
+      },
+      "minProperties": 1,
+      "additionalProperties": false
+    }
+  }
 }
 `
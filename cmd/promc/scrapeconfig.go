@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/prometheus/common/model"
+	"github.com/remiges-tech/serversage/promc"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// scrapeConfigFile is the part of a prometheus.yml that holds scrape jobs.
+type scrapeConfigFile struct {
+	ScrapeConfigs []scrapeJob `yaml:"scrape_configs"`
+}
+
+type scrapeJob struct {
+	JobName              string                `yaml:"job_name"`
+	MetricsPath          string                `yaml:"metrics_path,omitempty"`
+	ScrapeInterval       string                `yaml:"scrape_interval,omitempty"`
+	StaticConfigs        []scrapeStaticTargets `yaml:"static_configs,omitempty"`
+	FileSDConfigs        []scrapeFileSD        `yaml:"file_sd_configs,omitempty"`
+	RelabelConfigs       []scrapeRelabel       `yaml:"relabel_configs,omitempty"`
+	MetricRelabelConfigs []scrapeRelabel       `yaml:"metric_relabel_configs,omitempty"`
+}
+
+type scrapeStaticTargets struct {
+	Targets []string `yaml:"targets"`
+}
+
+type scrapeFileSD struct {
+	Files []string `yaml:"files"`
+}
+
+// scrapeRelabel is a relabeling rule in prometheus.yml. It has the fields of
+// monitorRelabel, which the Prometheus Operator spells in camel case.
+type scrapeRelabel struct {
+	SourceLabels []string `yaml:"source_labels,omitempty"`
+	Regex        string   `yaml:"regex,omitempty"`
+	TargetLabel  string   `yaml:"target_label,omitempty"`
+	Replacement  string   `yaml:"replacement,omitempty"`
+	Action       string   `yaml:"action"`
+}
+
+// scrapeOptions are the settings of a scrape job that do not come from the
+// configuration.
+type scrapeOptions struct {
+	Job            string
+	Targets        []string
+	FileSD         string
+	Path           string
+	Interval       string
+	TargetLabels   map[string]string
+	DropDeprecated bool
+	KeepDeclared   bool
+}
+
+func newScrapeConfigCmd() *cobra.Command {
+	var configPaths []string
+	var outputPath string
+	var options scrapeOptions
+
+	cmd := &cobra.Command{
+		Use:   "scrape-config",
+		Short: "Generates a prometheus.yml scrape job for the configured metrics",
+		Run: func(cmd *cobra.Command, args []string) {
+			config, err := loadConfigs(configPaths, false)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			snippet, err := generateScrapeConfig(config, options)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			if outputPath == "" {
+				os.Stdout.Write(snippet)
+				return
+			}
+			err = os.WriteFile(outputPath, snippet, 0o644)
+			if err != nil {
+				fmt.Printf("error writing output file: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringArrayVarP(&configPaths, "config", "c", nil, "Path to the configuration file, may be repeated to merge several files (required)")
+	cmd.Flags().StringVarP(&outputPath, "output", "o", "", "Path to the output file, defaults to standard output")
+	cmd.Flags().StringVar(&options.Job, "job", "", "Name of the scrape job (required)")
+	cmd.Flags().StringArrayVar(&options.Targets, "target", nil, "Address of a scraped instance as host:port, may be repeated")
+	cmd.Flags().StringVar(&options.FileSD, "file-sd", "", "Path or glob of file_sd target files, instead of --target")
+	cmd.Flags().StringVar(&options.Path, "path", "", "HTTP path serving the metrics, Prometheus defaults to /metrics")
+	cmd.Flags().StringVar(&options.Interval, "interval", "", "Scrape interval, defaults to the global scrape_interval")
+	cmd.Flags().StringToStringVar(&options.TargetLabels, "target-label", nil, "Label added to every scraped series, as name=value")
+	cmd.Flags().BoolVar(&options.DropDeprecated, "drop-deprecated", false, "Drop the series of deprecated metrics at scrape time")
+	cmd.Flags().BoolVar(&options.KeepDeclared, "keep-declared", false, "Drop every series that does not belong to a configured metric")
+	cmd.MarkFlagRequired("config")
+	cmd.MarkFlagRequired("job")
+
+	return cmd
+}
+
+// generateScrapeConfig returns a prometheus.yml snippet with a scrape job for
+// the metrics of config.
+func generateScrapeConfig(config *promc.MetricConfig, options scrapeOptions) ([]byte, error) {
+	if len(options.Targets) == 0 && options.FileSD == "" {
+		return nil, fmt.Errorf("either --target or --file-sd is required")
+	}
+	if len(options.Targets) > 0 && options.FileSD != "" {
+		return nil, fmt.Errorf("--target and --file-sd cannot be used together")
+	}
+	if options.Interval != "" {
+		if _, err := model.ParseDuration(options.Interval); err != nil {
+			return nil, fmt.Errorf("invalid interval: %v", err)
+		}
+	}
+	if err := validateTargetLabels(options.TargetLabels); err != nil {
+		return nil, err
+	}
+
+	job := scrapeJob{
+		JobName:        options.Job,
+		MetricsPath:    options.Path,
+		ScrapeInterval: options.Interval,
+	}
+	if options.FileSD != "" {
+		job.FileSDConfigs = []scrapeFileSD{{Files: []string{options.FileSD}}}
+	} else {
+		job.StaticConfigs = []scrapeStaticTargets{{Targets: options.Targets}}
+	}
+	for _, relabeling := range targetRelabelings(options.TargetLabels) {
+		job.RelabelConfigs = append(job.RelabelConfigs, scrapeRelabel(relabeling))
+	}
+	for _, relabeling := range metricRelabelings(config, options.DropDeprecated, options.KeepDeclared) {
+		job.MetricRelabelConfigs = append(job.MetricRelabelConfigs, scrapeRelabel(relabeling))
+	}
+
+	return yaml.Marshal(scrapeConfigFile{ScrapeConfigs: []scrapeJob{job}})
+}
@@ -0,0 +1,125 @@
+package main
+
+import "testing"
+
+func TestGenerateScrapeConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		options scrapeOptions
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "static targets",
+			options: scrapeOptions{Job: "shop", Targets: []string{"a:9090"}},
+			want: `scrape_configs:
+- job_name: shop
+  static_configs:
+  - targets:
+    - a:9090
+`,
+		},
+		{
+			name: "keep declared and drop deprecated",
+			options: scrapeOptions{
+				Job:            "shop",
+				Targets:        []string{"a:9090", "b:9090"},
+				Path:           "/m",
+				Interval:       "15s",
+				TargetLabels:   map[string]string{"team": "checkout"},
+				DropDeprecated: true,
+				KeepDeclared:   true,
+			},
+			want: `scrape_configs:
+- job_name: shop
+  metrics_path: /m
+  scrape_interval: 15s
+  static_configs:
+  - targets:
+    - a:9090
+    - b:9090
+  relabel_configs:
+  - target_label: team
+    replacement: checkout
+    action: replace
+  metric_relabel_configs:
+  - source_labels:
+    - __name__
+    regex: promc_deprecated_metric_calls_total|promc_unexpected_label_values_total|shop_cart_items|shop_latency_seconds(_bucket|_sum|_count)?|shop_orders_total
+    action: keep
+  - source_labels:
+    - __name__
+    regex: shop_cart_items
+    action: drop
+`,
+		},
+		{
+			name:    "file sd and drop deprecated",
+			options: scrapeOptions{Job: "shop", FileSD: "targets/*.json", DropDeprecated: true},
+			want: `scrape_configs:
+- job_name: shop
+  file_sd_configs:
+  - files:
+    - targets/*.json
+  metric_relabel_configs:
+  - source_labels:
+    - __name__
+    regex: shop_cart_items
+    action: drop
+`,
+		},
+		{
+			name:    "keep declared only",
+			options: scrapeOptions{Job: "shop", Targets: []string{"a:9090"}, KeepDeclared: true},
+			want: `scrape_configs:
+- job_name: shop
+  static_configs:
+  - targets:
+    - a:9090
+  metric_relabel_configs:
+  - source_labels:
+    - __name__
+    regex: promc_deprecated_metric_calls_total|promc_unexpected_label_values_total|shop_cart_items|shop_latency_seconds(_bucket|_sum|_count)?|shop_orders_total
+    action: keep
+`,
+		},
+		{
+			name:    "no targets",
+			options: scrapeOptions{Job: "shop"},
+			wantErr: "either --target or --file-sd is required",
+		},
+		{
+			name:    "targets and file sd",
+			options: scrapeOptions{Job: "shop", Targets: []string{"a:9090"}, FileSD: "targets/*.json"},
+			wantErr: "--target and --file-sd cannot be used together",
+		},
+		{
+			name:    "invalid interval",
+			options: scrapeOptions{Job: "shop", Targets: []string{"a:9090"}, Interval: "soon"},
+			wantErr: `invalid interval: not a valid duration string: "soon"`,
+		},
+		{
+			name:    "invalid target label",
+			options: scrapeOptions{Job: "shop", Targets: []string{"a:9090"}, TargetLabels: map[string]string{"team-name": "checkout"}},
+			wantErr: `invalid target label name "team-name"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := generateScrapeConfig(monitorTestConfig, tt.options)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("generateScrapeConfig() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("generateScrapeConfig() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
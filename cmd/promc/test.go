@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/remiges-tech/serversage/promc"
+	"github.com/spf13/cobra"
+)
+
+func newTestCmd() *cobra.Command {
+	var packageName string
+	var update bool
+
+	cmd := &cobra.Command{
+		Use:   "test [DIR]",
+		Short: "Compares the code generated for test configurations with golden files",
+		Long: `Generates code for every *.json configuration in DIR (testdata by default) and
+compares it with the golden file of the same name ending in .golden. Output
+settings such as api or build_tags are taken from each configuration, and
+environment variable references resolve to their defaults. Pass --update to
+rewrite the golden files with the current output.`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			dir := "testdata"
+			if len(args) > 0 {
+				dir = args[0]
+			}
+
+			configPaths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+			if err != nil {
+				fmt.Printf("error listing test configs: %v\n", err)
+				os.Exit(1)
+			}
+			if len(configPaths) == 0 {
+				fmt.Printf("no test configs found in %s\n", dir)
+				os.Exit(1)
+			}
+
+			failed := 0
+			for _, configPath := range configPaths {
+				goldenPath := strings.TrimSuffix(configPath, ".json") + ".golden"
+				if err := runGoldenTest(configPath, goldenPath, packageName, update); err != nil {
+					fmt.Printf("FAIL  %s: %v\n", configPath, err)
+					failed++
+					continue
+				}
+				fmt.Printf("ok    %s\n", configPath)
+			}
+			if failed > 0 {
+				fmt.Printf("%d of %d golden test(s) failed\n", failed, len(configPaths))
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&packageName, "package", "p", "metrics", "Package name for the generated code")
+	cmd.Flags().BoolVar(&update, "update", false, "Rewrite the golden files with the generated code")
+
+	return cmd
+}
+
+// runGoldenTest generates code for the config at configPath and compares it
+// with the golden file at goldenPath, or overwrites the golden file if update is set.
+// Environment variable references resolve to their defaults, so the result
+// does not depend on the caller's environment.
+func runGoldenTest(configPath, goldenPath, packageName string, update bool) error {
+	config, err := loadConfigsEnv([]string{configPath}, false, noEnv)
+	if err != nil {
+		return err
+	}
+	config.PackageName = packageName
+
+	got, err := promc.Generate(config)
+	if err != nil {
+		return err
+	}
+
+	if update {
+		return os.WriteFile(goldenPath, got, 0o644)
+	}
+
+	want, err := os.ReadFile(goldenPath)
+	if err != nil {
+		return fmt.Errorf("error reading golden file: %v", err)
+	}
+	if bytes.Equal(got, want) {
+		return nil
+	}
+
+	gotLines := strings.Split(string(got), "\n")
+	wantLines := strings.Split(string(want), "\n")
+	for i := 0; i < len(gotLines) || i < len(wantLines); i++ {
+		var gotLine, wantLine string
+		if i < len(gotLines) {
+			gotLine = gotLines[i]
+		}
+		if i < len(wantLines) {
+			wantLine = wantLines[i]
+		}
+		if gotLine != wantLine {
+			return fmt.Errorf("output differs from %s at line %d:\n  want: %s\n  got:  %s", goldenPath, i+1, wantLine, gotLine)
+		}
+	}
+	return fmt.Errorf("output differs from %s", goldenPath)
+}
@@ -0,0 +1,16 @@
+// Code generated by promc; DO NOT EDIT.
+
+package com.example.shop;
+
+public final class MetricNames {
+    private MetricNames() {}
+
+    /** Orders placed, by status. */
+    public static final String ORDERS_TOTAL = "shop_orders_total";
+    /** Time to check out, see *&#47;docs. */
+    public static final String CHECKOUT_DURATION_SECONDS = "shop_checkout_duration_seconds";
+    public static final String CART_ITEMS = "shop_cart_items";
+
+    public static final String LABEL_METHOD = "method";
+    public static final String LABEL_STATUS = "status";
+}
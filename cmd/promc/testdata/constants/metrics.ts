@@ -0,0 +1,10 @@
+// Code generated by promc; DO NOT EDIT.
+
+/** Orders placed, by status. */
+export const ORDERS_TOTAL = "shop_orders_total";
+/** Time to check out, see *&#47;docs. */
+export const CHECKOUT_DURATION_SECONDS = "shop_checkout_duration_seconds";
+export const CART_ITEMS = "shop_cart_items";
+
+export const LABEL_METHOD = "method";
+export const LABEL_STATUS = "status";
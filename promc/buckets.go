@@ -0,0 +1,129 @@
+package promc
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// bucketPresets are the named bucket layouts that may be used in place of an
+// explicit list of buckets.
+var bucketPresets = map[string][]float64{
+	// default is the client library's default layout for request latencies.
+	"default": prometheus.DefBuckets,
+	// latency_fast covers in-process and cache operations from 0.5ms to 1s.
+	"latency_fast": {0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+	// latency_slow covers remote calls and batch jobs from 50ms to 5m.
+	"latency_slow": {0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	// sizes covers payload sizes in bytes from 256B to 1GiB.
+	"sizes": prometheus.ExponentialBuckets(256, 4, 12),
+}
+
+var bucketGeneratorRegexp = regexp.MustCompile(`^\s*(\w+)\s*\((.*)\)\s*$`)
+
+// Buckets are the upper bounds of histogram buckets. In the config they are
+// either a list of numbers, the name of a preset, or a generator expression
+// such as exponential(0.001, 2, 15) or linear(0, 10, 20).
+type Buckets []float64
+
+func (b *Buckets) UnmarshalJSON(data []byte) error {
+	var values []float64
+	if err := json.Unmarshal(data, &values); err == nil {
+		*b = values
+		return nil
+	}
+
+	var expr string
+	if err := json.Unmarshal(data, &expr); err != nil {
+		return fmt.Errorf("buckets must be a list of numbers, a preset or a generator expression")
+	}
+	values, err := ExpandBuckets(expr)
+	if err != nil {
+		return err
+	}
+	*b = values
+	return nil
+}
+
+// ExpandBuckets returns the buckets described by a preset name or a generator expression.
+func ExpandBuckets(expr string) ([]float64, error) {
+	if preset, ok := bucketPresets[strings.TrimSpace(expr)]; ok {
+		return append([]float64(nil), preset...), nil
+	}
+
+	match := bucketGeneratorRegexp.FindStringSubmatch(expr)
+	if match == nil {
+		return nil, fmt.Errorf("unknown bucket preset %q, valid presets are %s", expr, strings.Join(BucketPresetNames(), ", "))
+	}
+	generator, rawArgs := match[1], strings.Split(match[2], ",")
+	if len(rawArgs) != 3 {
+		return nil, fmt.Errorf("%s: %s expects 3 arguments, got %d", expr, generator, len(rawArgs))
+	}
+	var args [3]float64
+	for i, rawArg := range rawArgs {
+		arg, err := strconv.ParseFloat(strings.TrimSpace(rawArg), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid argument %q", expr, strings.TrimSpace(rawArg))
+		}
+		args[i] = arg
+	}
+	start, step, count := args[0], args[1], int(args[2])
+	if float64(count) != args[2] || count < 1 {
+		return nil, fmt.Errorf("%s: count must be a positive integer", expr)
+	}
+
+	var values []float64
+	switch generator {
+	case "linear":
+		if step <= 0 {
+			return nil, fmt.Errorf("%s: width must be positive", expr)
+		}
+		values = prometheus.LinearBuckets(start, step, count)
+	case "exponential":
+		if start <= 0 {
+			return nil, fmt.Errorf("%s: start must be positive", expr)
+		}
+		if step <= 1 {
+			return nil, fmt.Errorf("%s: factor must be greater than 1", expr)
+		}
+		values = prometheus.ExponentialBuckets(start, step, count)
+	default:
+		return nil, fmt.Errorf("%s: unknown bucket generator %q, valid generators are linear and exponential", expr, generator)
+	}
+
+	// Round away floating point noise such as 0.30000000000000004 so the
+	// generated code shows the values the expression was meant to produce.
+	for i, value := range values {
+		values[i], _ = strconv.ParseFloat(strconv.FormatFloat(value, 'g', 12, 64), 64)
+	}
+	return values, nil
+}
+
+// NormalizeBucketExpr trims a bucket preset name and spaces the arguments of
+// a generator expression as in exponential(0.001, 2, 15).
+func NormalizeBucketExpr(expr string) string {
+	match := bucketGeneratorRegexp.FindStringSubmatch(expr)
+	if match == nil {
+		return strings.TrimSpace(expr)
+	}
+	args := strings.Split(match[2], ",")
+	for i, arg := range args {
+		args[i] = strings.TrimSpace(arg)
+	}
+	return match[1] + "(" + strings.Join(args, ", ") + ")"
+}
+
+// BucketPresetNames returns the names of the bucket presets in alphabetical order.
+func BucketPresetNames() []string {
+	names := make([]string, 0, len(bucketPresets))
+	for name := range bucketPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
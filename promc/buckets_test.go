@@ -0,0 +1,108 @@
+package promc
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestExpandBuckets(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    []float64
+		wantErr string
+	}{
+		{expr: "default", want: prometheus.DefBuckets},
+		{expr: " latency_fast ", want: []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}},
+		{expr: "linear(0, 10, 4)", want: []float64{0, 10, 20, 30}},
+		{expr: "linear(0.1,0.1,3)", want: []float64{0.1, 0.2, 0.3}},
+		{expr: "exponential(0.001, 2, 4)", want: []float64{0.001, 0.002, 0.004, 0.008}},
+		{expr: "exponential(1, 10, 3)", want: []float64{1, 10, 100}},
+		{expr: "fastest", wantErr: `unknown bucket preset "fastest", valid presets are default, latency_fast, latency_slow, sizes`},
+		{expr: "quadratic(1, 2, 3)", wantErr: `quadratic(1, 2, 3): unknown bucket generator "quadratic", valid generators are linear and exponential`},
+		{expr: "linear(0, 10)", wantErr: "linear(0, 10): linear expects 3 arguments, got 2"},
+		{expr: "linear(0, x, 4)", wantErr: `linear(0, x, 4): invalid argument "x"`},
+		{expr: "linear(0, 10, 0)", wantErr: "linear(0, 10, 0): count must be a positive integer"},
+		{expr: "linear(0, 10, 2.5)", wantErr: "linear(0, 10, 2.5): count must be a positive integer"},
+		{expr: "linear(0, 0, 4)", wantErr: "linear(0, 0, 4): width must be positive"},
+		{expr: "exponential(0, 2, 4)", wantErr: "exponential(0, 2, 4): start must be positive"},
+		{expr: "exponential(1, 1, 4)", wantErr: "exponential(1, 1, 4): factor must be greater than 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := ExpandBuckets(tt.expr)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("ExpandBuckets() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExpandBuckets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandBucketsCopiesPresets(t *testing.T) {
+	buckets, err := ExpandBuckets("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buckets[0] = 42
+	if prometheus.DefBuckets[0] == 42 {
+		t.Error("ExpandBuckets() returned the preset itself")
+	}
+}
+
+func TestBucketsUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		json    string
+		want    Buckets
+		wantErr bool
+	}{
+		{json: `[0.1, 1, 10]`, want: Buckets{0.1, 1, 10}},
+		{json: `"linear(1, 1, 3)"`, want: Buckets{1, 2, 3}},
+		{json: `"unknown"`, wantErr: true},
+		{json: `true`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.json, func(t *testing.T) {
+			var got Buckets
+			err := json.Unmarshal([]byte(tt.json), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmarshal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeBucketExpr(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{expr: "default", want: "default"},
+		{expr: "  latency_slow\n", want: "latency_slow"},
+		{expr: "exponential(0.001,2,15)", want: "exponential(0.001, 2, 15)"},
+		{expr: " linear( 0 ,  10, 20 ) ", want: "linear(0, 10, 20)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			if got := NormalizeBucketExpr(tt.expr); got != tt.want {
+				t.Errorf("NormalizeBucketExpr(%q) = %q, want %q", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+// Package promc generates Go code for Prometheus metrics from a promc
+// configuration.
+package promc
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+)
+
+// MetricConfig represents the YAML configuration file structure.
+type MetricConfig struct {
+	// SchemaVersion is the version of the configuration format. A missing
+	// version is treated as version 1.
+	SchemaVersion int      `json:"schema_version" yaml:"schema_version,omitempty"`
+	Metrics       []Metric `yaml:"metrics"`
+	Namespace     string   `json:"namespace" yaml:"namespace,omitempty"`
+	// Includes are further configuration files whose metrics are merged into
+	// this one. Relative paths are resolved against the including file.
+	Includes []string `yaml:"includes,omitempty"`
+	// API is the style of the generated API: "functions" (the default) for
+	// package-level metrics and record functions, or "struct" for a Metrics
+	// struct with record methods.
+	API string `yaml:"api,omitempty"`
+	// Interface adds a MetricsRecorder interface covering the record functions
+	// to the generated code, with a no-op implementation.
+	Interface bool `yaml:"interface,omitempty"`
+	// DI is the dependency injection framework to generate providers for with
+	// the struct API: "wire" for a Google Wire provider set or "fx" for an
+	// Uber fx module.
+	DI string `json:"di" yaml:"di,omitempty"`
+	// BuildTags is a //go:build expression added to the generated file.
+	BuildTags string `json:"build_tags" yaml:"build_tags,omitempty"`
+	// Header is text added as a comment at the top of the generated file.
+	Header string `yaml:"header,omitempty"`
+	// ImportAliases maps import paths to the names the generated file imports them as.
+	ImportAliases map[string]string `json:"import_aliases" yaml:"import_aliases,omitempty"`
+	PackageName   string            `yaml:"package_name"`
+	UniqueLabels  map[string]bool   `yaml:"-"`
+	// ValidatesLabels is set when any label restricts its allowed values or
+	// any metric is a stateset metric, whose states are validated.
+	ValidatesLabels bool `yaml:"-"`
+	// HasDeprecated is set when any metric is marked deprecated.
+	HasDeprecated bool `yaml:"-"`
+	// HasExemplars is set when any metric records exemplars.
+	HasExemplars bool `yaml:"-"`
+	// HasStability is set when any metric declares its stability level.
+	HasStability bool `yaml:"-"`
+	// HasDurations is set when any metric has a record wrapper taking a
+	// time.Duration.
+	HasDurations bool `yaml:"-"`
+	// HasInfo is set when any metric is an info metric.
+	HasInfo bool `yaml:"-"`
+	// HasStateSet is set when any metric is a stateset metric.
+	HasStateSet bool `yaml:"-"`
+	// HasRequiredLabels is set when any metric has a required label.
+	HasRequiredLabels bool `yaml:"-"`
+	// GRPC configures the generated gRPC interceptors.
+	GRPC *GRPCConfig `json:"grpc" yaml:"grpc,omitempty"`
+	// StdImports and Imports are the standard library and third-party
+	// packages imported by the generated code, by their default names, and
+	// TestStdImports and TestImports those imported by the generated test.
+	StdImports     []string `yaml:"-"`
+	Imports        []string `yaml:"-"`
+	TestStdImports []string `yaml:"-"`
+	TestImports    []string `yaml:"-"`
+}
+
+type Metric struct {
+	Name        string            `yaml:"name"`
+	Type        string            `yaml:"type"`
+	Labels      []Label           `yaml:"labels,omitempty"`
+	ConstLabels map[string]string `json:"const_labels" yaml:"const_labels,omitempty"`
+	Help        string            `yaml:"help,omitempty"`
+	// Unit is the base unit of the metric's values: seconds, milliseconds,
+	// bytes or ratio. It is appended to the name unless the name already ends
+	// in it.
+	Unit       string     `yaml:"unit,omitempty"`
+	Buckets    Buckets    `yaml:"buckets,omitempty"`
+	Objectives Objectives `yaml:"objectives,omitempty"`
+	// MaxAge and AgeBuckets configure the sliding window over which the
+	// quantiles of a summary are calculated.
+	MaxAge     Duration `json:"max_age" yaml:"max_age,omitempty"`
+	AgeBuckets uint32   `json:"age_buckets" yaml:"age_buckets,omitempty"`
+	Exemplars  bool     `yaml:"exemplars,omitempty"`
+	Deprecated bool     `yaml:"deprecated,omitempty"`
+	// RemovedAfter is the date (YYYY-MM-DD) after which a deprecated metric
+	// must be removed from the config.
+	RemovedAfter string `json:"removed_after" yaml:"removed_after,omitempty"`
+	// Stability is the stability level of the metric: alpha, beta or stable.
+	// Changes to alpha metrics are never breaking, while any change to a
+	// stable metric except its deprecation is.
+	Stability string `yaml:"stability,omitempty"`
+	// States are the states of a stateset metric, of which one is active
+	// for every label set.
+	States []string `yaml:"states,omitempty"`
+	// ConfiguredName is the name as written in the config, before the unit
+	// or _info suffix is appended to Name.
+	ConfiguredName string `json:"-" yaml:"-"`
+	// Namespace is the namespace of the config file the metric is defined in.
+	Namespace string `json:"-" yaml:"-"`
+	// Source is the path of the config file the metric is defined in.
+	Source string `json:"-" yaml:"-"`
+}
+
+// Label is a variable label of a metric. In the config it is either a plain
+// label name or an object carrying additional constraints.
+type Label struct {
+	Name          string   `json:"name" yaml:"name"`
+	AllowedValues []string `json:"allowed_values" yaml:"allowed_values,omitempty"`
+	// OnUnexpected is "other" (the default) to remap values outside
+	// AllowedValues to "other", or "reject" to drop the observation.
+	OnUnexpected string `json:"on_unexpected" yaml:"on_unexpected,omitempty"`
+	// Default replaces an empty value of the label in the record functions.
+	Default string `json:"default" yaml:"default,omitempty"`
+	// Required makes the record functions return an error instead of
+	// recording an empty value of the label.
+	Required bool `json:"required" yaml:"required,omitempty"`
+}
+
+// UnitWrapper returns the suffix of the record wrapper generated for the unit
+// of metric: Duration for seconds and milliseconds, Int64 for bytes, or ""
+// if it has none.
+func (metric Metric) UnitWrapper() string {
+	if metric.Type != "gauge" && metric.Type != "histogram" && metric.Type != "summary" {
+		return ""
+	}
+	switch metric.Unit {
+	case "seconds", "milliseconds":
+		return "Duration"
+	case "bytes":
+		return "Int64"
+	}
+	return ""
+}
+
+// HasRequiredLabels reports whether any label of metric is required, which
+// makes its record functions return an error.
+func (metric Metric) HasRequiredLabels() bool {
+	for _, label := range metric.Labels {
+		if label.Required {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *Label) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		l.Name = name
+		return nil
+	}
+	type label Label
+	return json.Unmarshal(data, (*label)(l))
+}
+
+// SnakeToCamel converts snake_case to CamelCase.
+func SnakeToCamel(s string) string {
+	parts := strings.Split(s, "_")
+	c := cases.Title(language.English)
+	for i, part := range parts {
+		parts[i] = c.String(part)
+	}
+	return strings.Join(parts, "")
+}
+
+// Units are the valid values of Metric.Unit.
+var Units = []string{"seconds", "milliseconds", "bytes", "ratio"}
+
+// SuffixedName returns the name of metric with its unit appended, before the
+// _total suffix of counters, unless it already ends in the unit. Info metrics
+// get the _info suffix instead.
+func SuffixedName(metric Metric) string {
+	if metric.Type == "info" && !strings.HasSuffix(metric.Name, "_info") {
+		return metric.Name + "_info"
+	}
+	if metric.Unit == "" {
+		return metric.Name
+	}
+	name, total := metric.Name, ""
+	if metric.Type == "counter" && strings.HasSuffix(name, "_total") {
+		name, total = strings.TrimSuffix(name, "_total"), "_total"
+	}
+	if !strings.HasSuffix(name, "_"+metric.Unit) {
+		name += "_" + metric.Unit
+	}
+	return name + total
+}
+
+// FormatFloat formats f as a Go float literal without an exponent.
+func FormatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,173 @@
+package promc
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"text/template"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Generate returns the formatted Go source of the metrics package described by
+// config, using config.PackageName as the package name.
+func Generate(config *MetricConfig) ([]byte, error) {
+	return render(config, metricsTemplate)
+}
+
+// GenerateTests returns the formatted Go source of a test file for the code
+// returned by Generate. It checks that every metric registers with a fresh
+// registry, accepts values for its labels and shows up when the registry is gathered.
+func GenerateTests(config *MetricConfig) ([]byte, error) {
+	return render(config, metricsTestTemplate)
+}
+
+// render executes the template text for config and formats the result.
+func render(config *MetricConfig, text string) ([]byte, error) {
+	// Populate unique labels
+	config.UniqueLabels = make(map[string]bool)
+	usesTime := config.GRPC != nil
+	for _, metric := range config.Metrics {
+		if metric.Deprecated {
+			config.HasDeprecated = true
+		}
+		if metric.Exemplars {
+			config.HasExemplars = true
+		}
+		if metric.Stability != "" {
+			config.HasStability = true
+		}
+		if metric.UnitWrapper() == "Duration" {
+			config.HasDurations = true
+		}
+		if metric.MaxAge != 0 {
+			usesTime = true
+		}
+		if metric.Type == "info" {
+			config.HasInfo = true
+		}
+		if metric.Type == "stateset" {
+			// Unknown states are counted like unexpected label values.
+			config.HasStateSet = true
+			config.ValidatesLabels = true
+		}
+		if metric.HasRequiredLabels() {
+			config.HasRequiredLabels = true
+		}
+		for _, label := range metric.Labels {
+			config.UniqueLabels[label.Name] = true
+			if len(label.AllowedValues) > 0 {
+				config.ValidatesLabels = true
+			}
+		}
+	}
+
+	err := resolveGRPC(config)
+	if err != nil {
+		return nil, err
+	}
+
+	config.StdImports = nil
+	if config.HasExemplars || config.GRPC != nil {
+		config.StdImports = append(config.StdImports, "context")
+	}
+	if config.HasRequiredLabels {
+		config.StdImports = append(config.StdImports, "errors")
+	}
+	if config.GRPC != nil || config.HasStateSet {
+		config.StdImports = append(config.StdImports, "strings")
+	}
+	if config.HasInfo || config.HasStateSet {
+		config.StdImports = append(config.StdImports, "sync")
+	}
+	if usesTime || config.HasDurations {
+		config.StdImports = append(config.StdImports, "time")
+	}
+	config.Imports = []string{"prometheus"}
+	if config.DI != "" {
+		config.Imports = append(config.Imports, config.DI)
+	}
+	if config.GRPC != nil {
+		config.Imports = append(config.Imports, "grpc", "status")
+	}
+
+	config.TestStdImports = nil
+	if config.HasExemplars {
+		config.TestStdImports = append(config.TestStdImports, "context")
+	}
+	if config.API == "struct" {
+		config.TestStdImports = append(config.TestStdImports, "errors")
+	}
+	config.TestStdImports = append(config.TestStdImports, "testing")
+	config.TestImports = []string{"prometheus"}
+
+	if config.API != "" && config.API != "functions" && config.API != "struct" {
+		return nil, fmt.Errorf("invalid API style %q, must be functions or struct", config.API)
+	}
+	switch config.DI {
+	case "":
+	case "wire", "fx":
+		if config.API != "struct" {
+			return nil, fmt.Errorf("%s providers require the struct API", config.DI)
+		}
+	default:
+		return nil, fmt.Errorf("invalid dependency injection framework %q, must be wire or fx", config.DI)
+	}
+	if config.BuildTags != "" {
+		if err := validateBuildTags(config.BuildTags); err != nil {
+			return nil, err
+		}
+	}
+
+	// Define a custom function map
+	funcMap, err := outputFuncs(config.ImportAliases)
+	if err != nil {
+		return nil, err
+	}
+	funcMap["snakeToCamel"] = SnakeToCamel
+	funcMap["formatFloat"] = FormatFloat
+	funcMap["duration"] = func(d Duration) string {
+		return durationExpr(d, funcMap["pkg"].(func(string) string)("time"))
+	}
+	funcMap["fullName"] = func(metric Metric) string {
+		return prometheus.BuildFQName(metric.Namespace, "", metric.Name)
+	}
+	funcMap["grpcField"] = func(label string) string {
+		return grpcLabels[label]
+	}
+	funcMap["receiver"] = func() string {
+		if config.API == "struct" {
+			return "(m *Metrics) "
+		}
+		return ""
+	}
+	funcMap["ref"] = func(name string) string {
+		// Record methods reach the metrics through their receiver.
+		if config.API == "struct" {
+			return "m." + name
+		}
+		return name
+	}
+
+	// Generate Go code from the template with the custom function map.
+	t, err := template.New("metrics").Funcs(funcMap).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing template: %v", err)
+	}
+
+	// Create a buffer to hold the executed template before formatting.
+	var buf bytes.Buffer
+
+	err = t.Execute(&buf, config)
+	if err != nil {
+		return nil, fmt.Errorf("error executing template: %v", err)
+	}
+
+	// Format the source code in the buffer.
+	formattedSource, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("error formatting source: %v", err)
+	}
+
+	return formattedSource, nil
+}
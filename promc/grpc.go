@@ -0,0 +1,86 @@
+package promc
+
+import "fmt"
+
+// grpcLabels are the label names the generated gRPC interceptors know how to
+// fill, mapped to the field of grpcCall holding their value.
+var grpcLabels = map[string]string{
+	"grpc_service": "service",
+	"grpc_method":  "method",
+	"grpc_code":    "code",
+	"grpc_type":    "typ",
+}
+
+// GRPCConfig configures the gRPC interceptors generated for the server and
+// client side of a service.
+type GRPCConfig struct {
+	Server *GRPCMetrics `yaml:"server,omitempty"`
+	Client *GRPCMetrics `yaml:"client,omitempty"`
+}
+
+// GRPCMetrics names the metrics the interceptors of one side record.
+type GRPCMetrics struct {
+	// RequestsMetric is a counter incremented once per call.
+	RequestsMetric string `json:"requests_metric" yaml:"requests_metric,omitempty"`
+	// LatencyMetric is a histogram observing the duration of every call in seconds.
+	LatencyMetric string `json:"latency_metric" yaml:"latency_metric,omitempty"`
+
+	Requests *Metric `json:"-" yaml:"-"`
+	Latency  *Metric `json:"-" yaml:"-"`
+}
+
+// resolveGRPC looks up the metrics referenced by the gRPC section of config
+// and checks that the interceptors can record them.
+func resolveGRPC(config *MetricConfig) error {
+	if config.GRPC == nil {
+		return nil
+	}
+
+	sides := []struct {
+		name    string
+		metrics *GRPCMetrics
+	}{
+		{"server", config.GRPC.Server},
+		{"client", config.GRPC.Client},
+	}
+	for _, side := range sides {
+		if side.metrics == nil {
+			continue
+		}
+		var err error
+		side.metrics.Requests, err = grpcMetric(config, side.metrics.RequestsMetric, "counter")
+		if err != nil {
+			return fmt.Errorf("grpc %s requests_metric: %v", side.name, err)
+		}
+		side.metrics.Latency, err = grpcMetric(config, side.metrics.LatencyMetric, "histogram")
+		if err != nil {
+			return fmt.Errorf("grpc %s latency_metric: %v", side.name, err)
+		}
+	}
+	return nil
+}
+
+// grpcMetric returns the metric called name, either as configured or with
+// its unit suffix, which must be of type metricType and only have labels the
+// interceptors can fill. An empty name returns nil.
+func grpcMetric(config *MetricConfig, name, metricType string) (*Metric, error) {
+	if name == "" {
+		return nil, nil
+	}
+	for i := range config.Metrics {
+		metric := &config.Metrics[i]
+		if metric.Name != name && metric.ConfiguredName != name {
+			continue
+		}
+		if metric.Type != metricType {
+			return nil, fmt.Errorf("%s must be a %s, not a %s", name, metricType, metric.Type)
+		}
+		for _, label := range metric.Labels {
+			if _, ok := grpcLabels[label.Name]; !ok {
+				return nil, fmt.Errorf("%s: label %s cannot be filled by the interceptors, use grpc_service, grpc_method, grpc_code or grpc_type", name, label.Name)
+			}
+		}
+		return metric, nil
+	}
+	return nil, fmt.Errorf("metric %s is not defined", name)
+}
@@ -0,0 +1,108 @@
+package promc
+
+import (
+	"fmt"
+	"go/build/constraint"
+	"go/token"
+	"strings"
+	"text/template"
+)
+
+// generatedImports maps the packages the generated code may import, by their
+// default package name, to their import paths.
+var generatedImports = map[string]string{
+	"context":    "context",
+	"errors":     "errors",
+	"strings":    "strings",
+	"sync":       "sync",
+	"testing":    "testing",
+	"time":       "time",
+	"prometheus": "github.com/prometheus/client_golang/prometheus",
+	"grpc":       "google.golang.org/grpc",
+	"wire":       "github.com/google/wire",
+	"fx":         "go.uber.org/fx",
+	"status":     "google.golang.org/grpc/status",
+}
+
+// outputFuncs returns the template functions that render the file header and
+// refer to imported packages by the aliases configured for their import paths.
+func outputFuncs(aliases map[string]string) (template.FuncMap, error) {
+	names := make(map[string]string)
+	for path, alias := range aliases {
+		name := ""
+		for defaultName, importPath := range generatedImports {
+			if importPath == path {
+				name = defaultName
+			}
+		}
+		if name == "" {
+			return nil, fmt.Errorf("import alias %s: %s is not imported by the generated code", alias, path)
+		}
+		if !token.IsIdentifier(alias) || alias == "_" {
+			return nil, fmt.Errorf("import alias %s: not a valid package name", alias)
+		}
+		names[name] = alias
+	}
+
+	pkg := func(name string) string {
+		if alias, ok := names[name]; ok {
+			return alias
+		}
+		return name
+	}
+	importSpec := func(name string) string {
+		if alias, ok := names[name]; ok {
+			return fmt.Sprintf("%s %q", alias, generatedImports[name])
+		}
+		return fmt.Sprintf("%q", generatedImports[name])
+	}
+
+	// importDecl returns an import declaration with the standard library
+	// packages and the other packages in separate groups.
+	importDecl := func(std, others []string) string {
+		var b strings.Builder
+		b.WriteString("import (\n")
+		for _, name := range std {
+			b.WriteString("\t" + importSpec(name) + "\n")
+		}
+		if len(std) > 0 && len(others) > 0 {
+			b.WriteString("\n")
+		}
+		for _, name := range others {
+			b.WriteString("\t" + importSpec(name) + "\n")
+		}
+		b.WriteString(")")
+		return b.String()
+	}
+
+	return template.FuncMap{
+		"pkg":        pkg,
+		"importDecl": importDecl,
+		"comment":    comment,
+	}, nil
+}
+
+// comment turns text into Go line comments, leaving lines that already are
+// comments untouched.
+func comment(text string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "//"):
+		case strings.TrimSpace(line) == "":
+			lines[i] = "//"
+		default:
+			lines[i] = "// " + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// validateBuildTags reports whether tags is a valid //go:build expression.
+func validateBuildTags(tags string) error {
+	_, err := constraint.Parse("//go:build " + tags)
+	if err != nil {
+		return fmt.Errorf("invalid build tags %q: %v", tags, err)
+	}
+	return nil
+}
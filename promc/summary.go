@@ -0,0 +1,85 @@
+package promc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// Objectives are the quantiles of a summary and their allowed absolute
+// errors. In the config they are an object mapping quantiles to errors, such
+// as {"0.5": 0.05, "0.99": 0.001}.
+type Objectives map[float64]float64
+
+func (o *Objectives) UnmarshalJSON(data []byte) error {
+	var raw map[string]float64
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("objectives must map quantiles to errors")
+	}
+
+	objectives := make(Objectives, len(raw))
+	for key, objectiveError := range raw {
+		quantile, err := strconv.ParseFloat(key, 64)
+		if err != nil {
+			return fmt.Errorf("invalid quantile %q", key)
+		}
+		if quantile < 0 || quantile > 1 {
+			return fmt.Errorf("quantile %s must be between 0 and 1", key)
+		}
+		if objectiveError < 0 || objectiveError > quantile || objectiveError > 1-quantile {
+			return fmt.Errorf("error %s of quantile %s must be between 0 and the distance of the quantile to 0 and 1", FormatFloat(objectiveError), key)
+		}
+		objectives[quantile] = objectiveError
+	}
+	*o = objectives
+	return nil
+}
+
+// Duration is the sliding window of a summary. In the config it is written
+// in the Prometheus duration format, such as 10m or 1h30m.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("durations must be strings such as 10m")
+	}
+	duration, err := model.ParseDuration(text)
+	if err != nil {
+		return err
+	}
+	if duration <= 0 {
+		return fmt.Errorf("duration %s must be positive", text)
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// durationUnits are the time constants generated durations are written in,
+// largest first.
+var durationUnits = []struct {
+	name string
+	unit time.Duration
+}{
+	{"Hour", time.Hour},
+	{"Minute", time.Minute},
+	{"Second", time.Second},
+	{"Millisecond", time.Millisecond},
+	{"Microsecond", time.Microsecond},
+	{"Nanosecond", time.Nanosecond},
+}
+
+// durationExpr returns a Go expression for d, such as 10 * time.Minute, in
+// the largest unit it is a multiple of. timePkg is the name the time package
+// is imported as.
+func durationExpr(d Duration, timePkg string) string {
+	for _, u := range durationUnits {
+		if time.Duration(d)%u.unit == 0 {
+			return fmt.Sprintf("%d * %s.%s", time.Duration(d)/u.unit, timePkg, u.name)
+		}
+	}
+	return fmt.Sprintf("%d", d)
+}
@@ -0,0 +1,1002 @@
+package promc
+
+const metricsTemplate = `
+{{- if .Header}}{{comment .Header}}
+
+{{end}}
+{{- if .BuildTags}}//go:build {{.BuildTags}}
+
+{{end -}}
+// Code generated by go generate; DO NOT EDIT.
+package {{.PackageName}}
+
+{{importDecl .StdImports .Imports}}
+
+{{- if eq .API "struct"}}
+    {{- template "structAPI" .}}
+{{- else}}
+    {{- template "functionAPI" .}}
+{{- end}}
+
+{{define "functionAPI"}}
+
+func init() {
+    // Automatically register metrics with Prometheus's default registry.
+    {{range .Metrics}}
+        {{pkg "prometheus"}}.MustRegister({{snakeToCamel .Name}})
+    {{- end}}
+    {{- if .ValidatesLabels}}
+        unexpectedLabelValues = mustRegisterOrReuse(unexpectedLabelValues).(*{{pkg "prometheus"}}.CounterVec)
+    {{- end}}
+    {{- if .HasDeprecated}}
+        deprecatedMetricCalls = mustRegisterOrReuse(deprecatedMetricCalls).(*{{pkg "prometheus"}}.CounterVec)
+    {{- end}}
+}
+
+{{- if or .ValidatesLabels .HasDeprecated}}
+
+// mustRegisterOrReuse registers c with Prometheus's default registerer. If
+// another generated package already registered an equal collector, that one
+// is returned instead, so the packages share it.
+func mustRegisterOrReuse(c {{pkg "prometheus"}}.Collector) {{pkg "prometheus"}}.Collector {
+    if err := {{pkg "prometheus"}}.Register(c); err != nil {
+        if are, ok := err.({{pkg "prometheus"}}.AlreadyRegisteredError); ok {
+            return are.ExistingCollector
+        }
+        panic(err)
+    }
+    return c
+}
+{{- end}}
+
+{{- if .ValidatesLabels}}
+
+// unexpectedLabelValues counts label values that were not in the allowed set
+// of their label and were therefore rejected or remapped to "other". It is
+// shared by all generated packages.
+var unexpectedLabelValues = {{template "unexpectedLabelValues" .}}
+{{- end}}
+
+{{- if .HasDeprecated}}
+
+// deprecatedMetricCalls counts calls to the record functions of deprecated
+// metrics, so their remaining users can be found before the metrics are
+// removed. It is shared by all generated packages.
+var deprecatedMetricCalls = {{template "deprecatedMetricCalls" .}}
+{{- end}}
+
+{{- if .HasExemplars}}
+
+// ExemplarFromContext returns the exemplar labels, typically the trace_id and
+// span_id of the span in ctx, attached to observations of metrics that have
+// exemplars enabled. No exemplar is attached while it is nil or when it
+// returns no labels. The labels must not exceed 128 runes in total.
+var ExemplarFromContext func(ctx {{pkg "context"}}.Context) {{pkg "prometheus"}}.Labels
+
+func exemplarLabels(ctx {{pkg "context"}}.Context) {{pkg "prometheus"}}.Labels {
+    if ctx == nil || ExemplarFromContext == nil {
+        return nil
+    }
+    return ExemplarFromContext(ctx)
+}
+{{- end}}
+{{- template "infoMetric" .}}
+{{- template "stateSetMetric" .}}
+
+{{- template "stability" .}}
+
+{{template "labelTypes" .}}
+
+{{range .Metrics}}
+    {{- template "deprecated" .}}
+    var {{snakeToCamel .Name}} = {{template "vec" .}}
+
+    {{- template "deprecated" .}}
+    func {{template "signature" .}} {
+        {{- template "recordBody" .}}
+    }
+    {{- template "unitWrapper" .}}
+{{- end}}
+{{- template "recorder" .}}
+{{- template "grpc" .}}
+{{- end}}
+
+{{define "structAPI"}}
+
+// Metrics holds the generated metrics. Every instance owns its own collectors,
+// so several instances can be registered with different registerers.
+type Metrics struct {
+    {{- range .Metrics}}
+        {{- template "deprecated" .}}
+        {{snakeToCamel .Name}} {{template "vecType" .}}
+    {{- end}}
+    {{- if .HasExemplars}}
+
+    // ExemplarFromContext returns the exemplar labels, typically the trace_id and
+    // span_id of the span in ctx, attached to observations of metrics that have
+    // exemplars enabled. No exemplar is attached while it is nil or when it
+    // returns no labels. The labels must not exceed 128 runes in total.
+    ExemplarFromContext func(ctx {{pkg "context"}}.Context) {{pkg "prometheus"}}.Labels
+    {{- end}}
+    {{- if .ValidatesLabels}}
+
+    // unexpectedLabelValues counts label values that were not in the allowed set
+    // of their label and were therefore rejected or remapped to "other".
+    unexpectedLabelValues *{{pkg "prometheus"}}.CounterVec
+    {{- end}}
+    {{- if .HasDeprecated}}
+
+    // deprecatedMetricCalls counts calls to the record methods of deprecated
+    // metrics, so their remaining users can be found before the metrics are removed.
+    deprecatedMetricCalls *{{pkg "prometheus"}}.CounterVec
+    {{- end}}
+}
+
+// NewMetrics creates the metrics and registers them with reg. If reg is nil,
+// they are registered with Prometheus's default registerer.
+func NewMetrics(reg {{pkg "prometheus"}}.Registerer) (*Metrics, error) {
+    if reg == nil {
+        reg = {{pkg "prometheus"}}.DefaultRegisterer
+    }
+
+    m := &Metrics{
+        {{- range .Metrics}}
+        {{snakeToCamel .Name}}: {{template "vec" .}},
+        {{- end}}
+        {{- if .ValidatesLabels}}
+        unexpectedLabelValues: {{template "unexpectedLabelValues" .}},
+        {{- end}}
+        {{- if .HasDeprecated}}
+        deprecatedMetricCalls: {{template "deprecatedMetricCalls" .}},
+        {{- end}}
+    }
+
+    collectors := []{{pkg "prometheus"}}.Collector{
+        {{- range .Metrics}}
+        m.{{snakeToCamel .Name}},
+        {{- end}}
+    }
+    // On error, unregister the collectors registered so far, so that reg is
+    // left as it was.
+    var registered []{{pkg "prometheus"}}.Collector
+    unregister := func() {
+        for _, collector := range registered {
+            reg.Unregister(collector)
+        }
+    }
+    for _, collector := range collectors {
+        if err := reg.Register(collector); err != nil {
+            unregister()
+            return nil, err
+        }
+        registered = append(registered, collector)
+    }
+    {{- if .ValidatesLabels}}
+
+    collector, err := registerOrReuse(reg, m.unexpectedLabelValues)
+    if err != nil {
+        unregister()
+        return nil, err
+    }
+    {{- if .HasDeprecated}}
+    // A collector shared with another package is left registered.
+    if collector == m.unexpectedLabelValues {
+        registered = append(registered, collector)
+    }
+    {{- end}}
+    m.unexpectedLabelValues = collector.(*{{pkg "prometheus"}}.CounterVec)
+    {{- end}}
+    {{- if .HasDeprecated}}
+
+    {{if .ValidatesLabels}}collector, err = {{else}}collector, err := {{end}}registerOrReuse(reg, m.deprecatedMetricCalls)
+    if err != nil {
+        unregister()
+        return nil, err
+    }
+    m.deprecatedMetricCalls = collector.(*{{pkg "prometheus"}}.CounterVec)
+    {{- end}}
+    return m, nil
+}
+
+{{- if or .ValidatesLabels .HasDeprecated}}
+
+// registerOrReuse registers c with reg. If another generated package already
+// registered an equal collector, that one is returned instead, so the
+// packages share it.
+func registerOrReuse(reg {{pkg "prometheus"}}.Registerer, c {{pkg "prometheus"}}.Collector) ({{pkg "prometheus"}}.Collector, error) {
+    if err := reg.Register(c); err != nil {
+        if are, ok := err.({{pkg "prometheus"}}.AlreadyRegisteredError); ok {
+            return are.ExistingCollector, nil
+        }
+        return nil, err
+    }
+    return c, nil
+}
+{{- end}}
+
+{{- if .HasExemplars}}
+
+func (m *Metrics) exemplarLabels(ctx {{pkg "context"}}.Context) {{pkg "prometheus"}}.Labels {
+    if ctx == nil || m.ExemplarFromContext == nil {
+        return nil
+    }
+    return m.ExemplarFromContext(ctx)
+}
+{{- end}}
+{{- template "infoMetric" .}}
+{{- template "stateSetMetric" .}}
+
+{{- template "stability" .}}
+
+{{template "labelTypes" .}}
+
+{{range .Metrics}}
+    {{- template "deprecated" .}}
+    func (m *Metrics) {{template "signature" .}} {
+        {{- template "recordBody" .}}
+    }
+    {{- template "unitWrapper" .}}
+{{end}}
+{{- template "recorder" .}}
+{{- template "providers" .}}
+{{- template "grpc" .}}
+{{- end}}
+
+{{define "providers"}}
+{{- if eq .DI "wire"}}
+
+// ProviderSet provides *Metrics for Google Wire{{if .Interface}} and binds
+// MetricsRecorder to it{{end}}. The injector has to provide the
+// prometheus.Registerer to register the metrics with.
+var ProviderSet = {{pkg "wire"}}.NewSet(
+    NewMetrics,
+    {{- if .Interface}}
+    {{pkg "wire"}}.Bind(new(MetricsRecorder), new(*Metrics)),
+    {{- end}}
+)
+{{- else if eq .DI "fx"}}
+
+// Module provides *Metrics{{if .Interface}} and MetricsRecorder{{end}} to an
+// Uber fx application. The metrics are registered with the
+// prometheus.Registerer of the application, or with Prometheus's default
+// registerer if it provides none.
+var Module = {{pkg "fx"}}.Module("{{.PackageName}}",
+    {{pkg "fx"}}.Provide(
+        func(params metricsParams) (*Metrics, error) {
+            return NewMetrics(params.Registerer)
+        },
+        {{- if .Interface}}
+        func(m *Metrics) MetricsRecorder {
+            return m
+        },
+        {{- end}}
+    ),
+)
+
+// metricsParams are the dependencies of Module.
+type metricsParams struct {
+    {{pkg "fx"}}.In
+
+    Registerer {{pkg "prometheus"}}.Registerer ` + "`" + `optional:"true"` + "`" + `
+}
+{{- end}}
+{{- end}}
+
+{{define "grpc"}}
+{{- if .GRPC}}
+{{- $receiver := ""}}
+{{- if eq .API "struct"}}{{$receiver = "(m *Metrics) "}}{{end}}
+
+// grpcCall holds the label values of a finished gRPC call.
+type grpcCall struct {
+    service, method, code, typ string
+}
+
+func newGRPCCall(fullMethod, typ string, err error) grpcCall {
+    service, method, ok := {{pkg "strings"}}.Cut({{pkg "strings"}}.TrimPrefix(fullMethod, "/"), "/")
+    if !ok {
+        service, method = "unknown", fullMethod
+    }
+    return grpcCall{service: service, method: method, code: {{pkg "status"}}.Code(err).String(), typ: typ}
+}
+
+func grpcStreamType(clientStream, serverStream bool) string {
+    switch {
+    case clientStream && serverStream:
+        return "bidi_stream"
+    case clientStream:
+        return "client_stream"
+    case serverStream:
+        return "server_stream"
+    }
+    return "unary"
+}
+
+{{- with .GRPC.Server}}
+
+// UnaryServerInterceptor returns a gRPC server interceptor that records
+// {{template "grpcMetricNames" .}} for unary calls.
+func {{$receiver}}UnaryServerInterceptor() {{pkg "grpc"}}.UnaryServerInterceptor {
+    return func(ctx {{pkg "context"}}.Context, req interface{}, info *{{pkg "grpc"}}.UnaryServerInfo, handler {{pkg "grpc"}}.UnaryHandler) (interface{}, error) {
+        start := {{pkg "time"}}.Now()
+        resp, err := handler(ctx, req)
+        {{ref "recordGRPCServerCall"}}(ctx, newGRPCCall(info.FullMethod, "unary", err), start)
+        return resp, err
+    }
+}
+
+// StreamServerInterceptor returns a gRPC server interceptor that records
+// {{template "grpcMetricNames" .}} for streaming calls.
+func {{$receiver}}StreamServerInterceptor() {{pkg "grpc"}}.StreamServerInterceptor {
+    return func(srv interface{}, ss {{pkg "grpc"}}.ServerStream, info *{{pkg "grpc"}}.StreamServerInfo, handler {{pkg "grpc"}}.StreamHandler) error {
+        start := {{pkg "time"}}.Now()
+        err := handler(srv, ss)
+        {{ref "recordGRPCServerCall"}}(ss.Context(), newGRPCCall(info.FullMethod, grpcStreamType(info.IsClientStream, info.IsServerStream), err), start)
+        return err
+    }
+}
+
+func {{$receiver}}recordGRPCServerCall(ctx {{pkg "context"}}.Context, call grpcCall, start {{pkg "time"}}.Time) {
+    {{- template "grpcRecord" .}}
+}
+{{- end}}
+
+{{- with .GRPC.Client}}
+
+// UnaryClientInterceptor returns a gRPC client interceptor that records
+// {{template "grpcMetricNames" .}} for unary calls.
+func {{$receiver}}UnaryClientInterceptor() {{pkg "grpc"}}.UnaryClientInterceptor {
+    return func(ctx {{pkg "context"}}.Context, fullMethod string, req, reply interface{}, cc *{{pkg "grpc"}}.ClientConn, invoker {{pkg "grpc"}}.UnaryInvoker, opts ...{{pkg "grpc"}}.CallOption) error {
+        start := {{pkg "time"}}.Now()
+        err := invoker(ctx, fullMethod, req, reply, cc, opts...)
+        {{ref "recordGRPCClientCall"}}(ctx, newGRPCCall(fullMethod, "unary", err), start)
+        return err
+    }
+}
+
+// StreamClientInterceptor returns a gRPC client interceptor that records
+// {{template "grpcMetricNames" .}} for streaming calls. Only the
+// establishment of the stream is measured, not its lifetime.
+func {{$receiver}}StreamClientInterceptor() {{pkg "grpc"}}.StreamClientInterceptor {
+    return func(ctx {{pkg "context"}}.Context, desc *{{pkg "grpc"}}.StreamDesc, cc *{{pkg "grpc"}}.ClientConn, fullMethod string, streamer {{pkg "grpc"}}.Streamer, opts ...{{pkg "grpc"}}.CallOption) ({{pkg "grpc"}}.ClientStream, error) {
+        start := {{pkg "time"}}.Now()
+        stream, err := streamer(ctx, desc, cc, fullMethod, opts...)
+        {{ref "recordGRPCClientCall"}}(ctx, newGRPCCall(fullMethod, grpcStreamType(desc.ClientStreams, desc.ServerStreams), err), start)
+        return stream, err
+    }
+}
+
+func {{$receiver}}recordGRPCClientCall(ctx {{pkg "context"}}.Context, call grpcCall, start {{pkg "time"}}.Time) {
+    {{- template "grpcRecord" .}}
+}
+{{- end}}
+{{- end}}
+{{- end}}
+
+{{define "grpcMetricNames" -}}
+    {{- if and .Requests .Latency}}{{.Requests.Name}} and {{.Latency.Name}}
+    {{- else if .Requests}}{{.Requests.Name}}
+    {{- else if .Latency}}{{.Latency.Name}}
+    {{- else}}no metrics
+    {{- end}}
+{{- end}}
+
+{{define "grpcRecord"}}
+    {{- with .Requests}}
+    {{ref "Record"}}{{snakeToCamel .Name}}({{if .Exemplars}}ctx, {{end}}{{template "grpcLabelValues" .}})
+    {{- end}}
+    {{- with .Latency}}
+    {{ref "Record"}}{{snakeToCamel .Name}}({{if .Exemplars}}ctx, {{end}}{{template "grpcLabelValues" .}}
+    {{- if eq .Unit "milliseconds"}}float64({{pkg "time"}}.Since(start))/float64({{pkg "time"}}.Millisecond)
+    {{- else}}{{pkg "time"}}.Since(start).Seconds()
+    {{- end}})
+    {{- end}}
+{{- end}}
+
+{{define "grpcLabelValues" -}}
+    {{- range .Labels}}{{snakeToCamel .Name}}(call.{{grpcField .Name}}), {{end}}
+{{- end}}
+
+{{define "infoMetric"}}
+{{- if .HasInfo}}
+
+// infoMetric is a collector exposing a single series with the value 1 whose
+// label values are replaced atomically, so a scrape never sees two or none.
+type infoMetric struct {
+    desc   *{{pkg "prometheus"}}.Desc
+    mu     {{pkg "sync"}}.Mutex
+    values []string
+}
+
+func newInfoMetric(desc *{{pkg "prometheus"}}.Desc) *infoMetric {
+    return &infoMetric{desc: desc}
+}
+
+func (i *infoMetric) Describe(ch chan<- *{{pkg "prometheus"}}.Desc) {
+    ch <- i.desc
+}
+
+func (i *infoMetric) Collect(ch chan<- {{pkg "prometheus"}}.Metric) {
+    i.mu.Lock()
+    values := i.values
+    i.mu.Unlock()
+    if values != nil {
+        ch <- {{pkg "prometheus"}}.MustNewConstMetric(i.desc, {{pkg "prometheus"}}.GaugeValue, 1, values...)
+    }
+}
+
+func (i *infoMetric) set(values ...string) {
+    i.mu.Lock()
+    i.values = append([]string{}, values...)
+    i.mu.Unlock()
+}
+{{- end}}
+{{- end}}
+
+{{define "stateSetMetric"}}
+{{- if .HasStateSet}}
+
+// stateSetMetric is a collector exposing a series for every state of every
+// label set, with the value 1 for the active state and 0 for the others. The
+// active state is replaced atomically, so a scrape never sees two or none.
+type stateSetMetric struct {
+    desc   *{{pkg "prometheus"}}.Desc
+    states []string
+    mu     {{pkg "sync"}}.Mutex
+    active map[string]stateSetValue
+}
+
+// stateSetValue is the active state of a label set of a stateSetMetric.
+type stateSetValue struct {
+    values []string
+    state  string
+}
+
+func newStateSetMetric(desc *{{pkg "prometheus"}}.Desc, states ...string) *stateSetMetric {
+    return &stateSetMetric{desc: desc, states: states, active: make(map[string]stateSetValue)}
+}
+
+func (s *stateSetMetric) Describe(ch chan<- *{{pkg "prometheus"}}.Desc) {
+    ch <- s.desc
+}
+
+func (s *stateSetMetric) Collect(ch chan<- {{pkg "prometheus"}}.Metric) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    for _, active := range s.active {
+        for _, state := range s.states {
+            value := 0.0
+            if state == active.state {
+                value = 1
+            }
+            ch <- {{pkg "prometheus"}}.MustNewConstMetric(s.desc, {{pkg "prometheus"}}.GaugeValue, value, append(append([]string{}, active.values...), state)...)
+        }
+    }
+}
+
+func (s *stateSetMetric) set(state string, values ...string) {
+    s.mu.Lock()
+    s.active[{{pkg "strings"}}.Join(values, "\xff")] = stateSetValue{values: append([]string{}, values...), state: state}
+    s.mu.Unlock()
+}
+{{- end}}
+{{- end}}
+
+{{define "stability"}}
+{{- if .HasStability}}
+
+// MetricStability maps the full names of the metrics that declare a stability
+// level to that level: alpha, beta or stable.
+var MetricStability = map[string]string{
+    {{- range .Metrics}}
+    {{- if .Stability}}
+    "{{fullName .}}": "{{.Stability}}",
+    {{- end}}
+    {{- end}}
+}
+{{- end}}
+{{- end}}
+
+{{define "labelTypes"}}
+{{- range $label, $_ := .UniqueLabels}}
+    type {{snakeToCamel $label}} string
+{{- end}}
+{{- range .Metrics}}
+{{- if eq .Type "stateset"}}
+{{- $name := snakeToCamel .Name}}
+
+// {{$name}}Value is a state of {{.Name}}.
+type {{$name}}Value string
+
+const (
+    {{- range .States}}
+    {{$name}}{{snakeToCamel .}} {{$name}}Value = "{{.}}"
+    {{- end}}
+)
+{{- end}}
+{{- end}}
+{{- end}}
+
+{{define "unexpectedLabelValues" -}}
+{{pkg "prometheus"}}.NewCounterVec(
+    {{pkg "prometheus"}}.CounterOpts{
+        Name: "promc_unexpected_label_values_total",
+        Help: "The number of label values rejected or remapped because they were not allowed.",
+    },
+    []string{"metric", "label"},
+)
+{{- end}}
+
+{{define "deprecatedMetricCalls" -}}
+{{pkg "prometheus"}}.NewCounterVec(
+    {{pkg "prometheus"}}.CounterOpts{
+        Name: "promc_deprecated_metric_calls_total",
+        Help: "The number of times a deprecated metric was recorded.",
+    },
+    []string{"metric"},
+)
+{{- end}}
+
+{{define "vecType" -}}
+    {{- if eq .Type "counter"}}*{{pkg "prometheus"}}.CounterVec
+    {{- else if eq .Type "gauge"}}*{{pkg "prometheus"}}.GaugeVec
+    {{- else if eq .Type "histogram"}}*{{pkg "prometheus"}}.HistogramVec
+    {{- else if eq .Type "summary"}}*{{pkg "prometheus"}}.SummaryVec
+    {{- else if eq .Type "info"}}*infoMetric
+    {{- else if eq .Type "stateset"}}*stateSetMetric
+    {{- end}}
+{{- end}}
+
+{{define "vec" -}}
+    {{- if eq .Type "info"}}newInfoMetric({{template "desc" .}})
+    {{- else if eq .Type "stateset"}}newStateSetMetric(
+        {{template "desc" .}},
+        {{- range .States}}
+        "{{.}}",
+        {{- end}}
+    )
+    {{- else}}
+    {{- if eq .Type "counter"}}{{pkg "prometheus"}}.NewCounterVec(
+        {{pkg "prometheus"}}.CounterOpts{
+    {{- else if eq .Type "gauge"}}{{pkg "prometheus"}}.NewGaugeVec(
+        {{pkg "prometheus"}}.GaugeOpts{
+    {{- else if eq .Type "histogram"}}{{pkg "prometheus"}}.NewHistogramVec(
+        {{pkg "prometheus"}}.HistogramOpts{
+    {{- else if eq .Type "summary"}}{{pkg "prometheus"}}.NewSummaryVec(
+        {{pkg "prometheus"}}.SummaryOpts{
+    {{- end}}
+            {{- if .Namespace}}
+            Namespace: "{{.Namespace}}",
+            {{- end}}
+            Name: "{{.Name}}",
+            Help: "{{.Help}}",
+            {{- if .ConstLabels}}
+            ConstLabels: {{pkg "prometheus"}}.Labels{
+                {{- range $label, $value := .ConstLabels}}
+                "{{$label}}": {{printf "%q" $value}},
+                {{- end}}
+            },
+            {{- end}}
+            {{- if eq .Type "histogram"}}
+            Buckets: []float64{ {{- range .Buckets}}{{formatFloat .}},{{- end}} },
+            {{- end}}
+            {{- if .Objectives}}
+            Objectives: map[float64]float64{ {{- range $quantile, $error := .Objectives}}{{formatFloat $quantile}}: {{formatFloat $error}},{{- end}} },
+            {{- end}}
+            {{- if .MaxAge}}
+            MaxAge: {{duration .MaxAge}},
+            {{- end}}
+            {{- if .AgeBuckets}}
+            AgeBuckets: {{.AgeBuckets}},
+            {{- end}}
+        },
+        []string{ {{- range .Labels}}"{{.Name}}",{{- end}} },
+    )
+    {{- end}}
+{{- end}}
+
+{{define "desc" -}}
+{{pkg "prometheus"}}.NewDesc(
+    "{{fullName .}}",
+    "{{.Help}}",
+    []string{ {{- range .Labels}}"{{.Name}}",{{- end}}{{if eq .Type "stateset"}}"{{fullName .}}",{{end}} },
+    {{- if .ConstLabels}}
+    {{pkg "prometheus"}}.Labels{
+        {{- range $label, $value := .ConstLabels}}
+        "{{$label}}": {{printf "%q" $value}},
+        {{- end}}
+    },
+    {{- else}}
+    nil,
+    {{- end}}
+)
+{{- end}}
+
+{{define "signature" -}}
+    {{template "recordFunc" .}}(
+    {{- if .Exemplars}}ctx {{pkg "context"}}.Context, {{end}}
+    {{- range .Labels}}{{snakeToCamel .Name}} {{snakeToCamel .Name}},{{- end}}
+    {{- if eq .Type "stateset"}} state {{snakeToCamel .Name}}Value
+    {{- else if and (ne .Type "counter") (ne .Type "info")}} value float64{{end -}}
+    ){{if .HasRequiredLabels}} error{{end}}
+{{- end}}
+
+{{define "recordFunc" -}}
+    {{if or (eq .Type "info") (eq .Type "stateset")}}Set{{else}}Record{{end}}{{snakeToCamel .Name}}
+{{- end}}
+
+{{define "unitWrapper"}}
+{{- if .UnitWrapper}}
+{{- $name := snakeToCamel .Name}}
+
+// Record{{$name}}
+{{- if eq .Unit "bytes"}}Int64 records an int64 number of bytes with Record{{$name}}.
+{{- else}}Duration records a time.Duration with Record{{$name}}, converted to {{.Unit}}.
+{{- end}}
+{{- if .Deprecated}}
+//
+{{- end}}
+{{- template "deprecated" .}}
+func {{receiver}}{{template "unitSignature" .}} {
+    {{if .HasRequiredLabels}}return {{end}}{{ref "Record"}}{{$name}}({{if .Exemplars}}ctx, {{end}}{{range .Labels}}{{snakeToCamel .Name}}, {{end}}
+    {{- if eq .Unit "seconds"}}value.Seconds()
+    {{- else if eq .Unit "milliseconds"}}float64(value)/float64({{pkg "time"}}.Millisecond)
+    {{- else}}float64(value)
+    {{- end}})
+}
+{{- end}}
+{{- end}}
+
+{{define "unitSignature" -}}
+    Record{{snakeToCamel .Name}}{{.UnitWrapper}}(
+    {{- if .Exemplars}}ctx {{pkg "context"}}.Context, {{end}}
+    {{- range .Labels}}{{snakeToCamel .Name}} {{snakeToCamel .Name}}, {{end}}
+    {{- if eq .Unit "bytes"}}value int64{{else}}value {{pkg "time"}}.Duration{{end -}}
+    ){{if .HasRequiredLabels}} error{{end}}
+{{- end}}
+
+{{define "recordArgs" -}}
+    {{- if .Exemplars}}ctx, {{end}}
+    {{- range .Labels}}{{snakeToCamel .Name}}, {{end}}
+    {{- if eq .Type "stateset"}}state
+    {{- else if and (ne .Type "counter") (ne .Type "info")}}value
+    {{- end}}
+{{- end}}
+
+{{define "recorder"}}
+{{- if .Interface}}
+
+// MetricsRecorder records the generated metrics. Packages that depend on it
+// instead of the generated {{if eq .API "struct"}}Metrics{{else}}functions{{end}} can use NopMetricsRecorder in tests or
+// when metrics are disabled.
+type MetricsRecorder interface {
+    {{- range .Metrics}}
+    {{- template "deprecated" .}}
+    {{template "signature" .}}
+    {{- if .UnitWrapper}}
+    {{- template "deprecated" .}}
+    {{template "unitSignature" .}}
+    {{- end}}
+    {{- end}}
+}
+{{- if eq .API "struct"}}
+
+var _ MetricsRecorder = (*Metrics)(nil)
+{{- else}}
+
+// Recorder is the MetricsRecorder that records to the package-level metrics.
+var Recorder MetricsRecorder = recorder{}
+
+type recorder struct{}
+{{- range .Metrics}}
+
+func (recorder) {{template "signature" .}} {
+    {{if .HasRequiredLabels}}return {{end}}{{template "recordFunc" .}}({{template "recordArgs" .}})
+}
+{{- if .UnitWrapper}}
+
+func (recorder) {{template "unitSignature" .}} {
+    {{if .HasRequiredLabels}}return {{end}}Record{{snakeToCamel .Name}}{{.UnitWrapper}}({{if .Exemplars}}ctx, {{end}}{{range .Labels}}{{snakeToCamel .Name}}, {{end}}value)
+}
+{{- end}}
+{{- end}}
+{{- end}}
+
+// NopMetricsRecorder is a MetricsRecorder that records nothing.
+type NopMetricsRecorder struct{}
+{{- range .Metrics}}
+
+func (NopMetricsRecorder) {{template "signature" .}} {
+    {{- if .HasRequiredLabels}}
+    return nil
+    {{- end}}
+}
+{{- if .UnitWrapper}}
+
+func (NopMetricsRecorder) {{template "unitSignature" .}} {
+    {{- if .HasRequiredLabels}}
+    return nil
+    {{- end}}
+}
+{{- end}}
+{{- end}}
+{{- end}}
+{{- end}}
+
+{{define "labelValues" -}}
+    {{pkg "prometheus"}}.Labels{
+        {{- range .Labels}}
+        "{{.Name}}": string({{snakeToCamel .Name}}),
+        {{- end}}
+    }
+{{- end}}
+
+{{define "recordBody"}}
+    {{- if .Deprecated}}
+    {{ref "deprecatedMetricCalls"}}.WithLabelValues("{{.Name}}").Inc()
+    {{- end}}
+    {{- template "defaultLabels" .}}
+    {{- template "requireLabels" .}}
+    {{- template "checkLabels" .}}
+    {{- if eq .Type "counter"}}
+        {{- if .Exemplars}}
+        counter := {{ref (snakeToCamel .Name)}}.With({{template "labelValues" .}})
+        if exemplar := {{ref "exemplarLabels"}}(ctx); len(exemplar) > 0 {
+            counter.({{pkg "prometheus"}}.ExemplarAdder).AddWithExemplar(1, exemplar)
+            {{template "return" .}}
+        }
+        counter.Inc()
+        {{- else}}
+        {{ref (snakeToCamel .Name)}}.With({{template "labelValues" .}}).Inc()
+        {{- end}}
+    {{- else if eq .Type "info"}}
+        {{ref (snakeToCamel .Name)}}.set({{range $i, $label := .Labels}}{{if $i}}, {{end}}string({{snakeToCamel $label.Name}}){{end}})
+    {{- else if eq .Type "stateset"}}
+        {{- $name := snakeToCamel .Name}}
+        switch state {
+        case {{range $i, $state := .States}}{{if $i}}, {{end}}{{$name}}{{snakeToCamel $state}}{{end}}:
+        default:
+            {{ref "unexpectedLabelValues"}}.WithLabelValues("{{.Name}}", "{{fullName .}}").Inc()
+            {{template "return" .}}
+        }
+        {{ref $name}}.set(string(state){{range .Labels}}, string({{snakeToCamel .Name}}){{end}})
+    {{- else if eq .Type "gauge"}}
+        {{ref (snakeToCamel .Name)}}.With({{template "labelValues" .}}).Set(value)
+    {{- else if eq .Type "histogram"}}
+        {{- if .Exemplars}}
+        observer := {{ref (snakeToCamel .Name)}}.With({{template "labelValues" .}})
+        if exemplar := {{ref "exemplarLabels"}}(ctx); len(exemplar) > 0 {
+            observer.({{pkg "prometheus"}}.ExemplarObserver).ObserveWithExemplar(value, exemplar)
+            {{template "return" .}}
+        }
+        observer.Observe(value)
+        {{- else}}
+        {{ref (snakeToCamel .Name)}}.With({{template "labelValues" .}}).Observe(value)
+        {{- end}}
+    {{- else if eq .Type "summary"}}
+        {{ref (snakeToCamel .Name)}}.With({{template "labelValues" .}}).Observe(value)
+    {{- end}}
+    {{- if .HasRequiredLabels}}
+    return nil
+    {{- end}}
+{{- end}}
+
+{{define "return" -}}
+    return{{if .HasRequiredLabels}} nil{{end}}
+{{- end}}
+
+{{define "requireLabels"}}
+    {{- $metric := .}}
+    {{- range .Labels}}
+        {{- if .Required}}
+            if {{snakeToCamel .Name}} == "" {
+                return {{pkg "errors"}}.New("{{fullName $metric}}: required label {{.Name}} is empty")
+            }
+        {{- end}}
+    {{- end}}
+{{- end}}
+
+{{define "defaultLabels"}}
+    {{- range .Labels}}
+        {{- if .Default}}
+            if {{snakeToCamel .Name}} == "" {
+                {{snakeToCamel .Name}} = {{printf "%q" .Default}}
+            }
+        {{- end}}
+    {{- end}}
+{{- end}}
+
+{{define "checkLabels"}}
+    {{- $metric := .}}
+    {{- range .Labels}}
+        {{- if .AllowedValues}}
+            switch {{snakeToCamel .Name}} {
+            case {{range $i, $value := .AllowedValues}}{{if $i}}, {{end}}{{printf "%q" $value}}{{end}}:
+            default:
+                {{ref "unexpectedLabelValues"}}.WithLabelValues("{{$metric.Name}}", "{{.Name}}").Inc()
+                {{- if eq .OnUnexpected "reject"}}
+                {{template "return" $metric}}
+                {{- else}}
+                {{snakeToCamel .Name}} = "other"
+                {{- end}}
+            }
+        {{- end}}
+    {{- end}}
+{{- end}}
+
+{{define "deprecated"}}
+    {{- if .Deprecated}}
+        // Deprecated: {{.Name}} is deprecated
+        {{- if .RemovedAfter}} and will be removed after {{.RemovedAfter}}{{end}}.
+    {{- end}}
+{{- end}}
+`
+
+const metricsTestTemplate = `
+{{- if .Header}}{{comment .Header}}
+
+{{end}}
+{{- if .BuildTags}}//go:build {{.BuildTags}}
+
+{{end -}}
+// Code generated by go generate; DO NOT EDIT.
+package {{.PackageName}}
+
+{{importDecl .TestStdImports .TestImports}}
+
+func TestGeneratedMetrics(t *testing.T) {
+{{- if eq .API "struct"}}
+    reg := {{pkg "prometheus"}}.NewPedanticRegistry()
+    m, err := NewMetrics(reg)
+    if err != nil {
+        t.Fatalf("NewMetrics: %v", err)
+    }
+{{end}}
+    tests := []struct {
+        name      string
+        {{- if ne .API "struct"}}
+        collector {{pkg "prometheus"}}.Collector
+        {{- end}}
+        record    func()
+    }{
+        {{- range .Metrics}}
+        {
+            name: "{{if .Namespace}}{{.Namespace}}_{{end}}{{.Name}}",
+            {{- if ne $.API "struct"}}
+            collector: {{snakeToCamel .Name}},
+            {{- end}}
+            record: func() {
+                {{- if eq $.API "struct"}}m.{{end}}{{if or (eq .Type "info") (eq .Type "stateset")}}Set{{else}}Record{{end}}{{snakeToCamel .Name}}(
+                {{- if .Exemplars}}{{pkg "context"}}.Background(), {{end}}
+                {{- template "testLabelValues" .}}
+                {{- if eq .Type "stateset"}}{{printf "%q" (index .States 0)}}
+                {{- else if and (ne .Type "counter") (ne .Type "info")}}1{{end -}}
+                )
+            },
+        },
+        {{- end}}
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            {{- if ne .API "struct"}}
+            reg := {{pkg "prometheus"}}.NewPedanticRegistry()
+            if err := reg.Register(tt.collector); err != nil {
+                t.Fatalf("registering %s: %v", tt.name, err)
+            }
+            {{- end}}
+
+            tt.record()
+
+            families, err := reg.Gather()
+            if err != nil {
+                t.Fatalf("gathering %s: %v", tt.name, err)
+            }
+            for _, family := range families {
+                if family.GetName() == tt.name {
+                    return
+                }
+            }
+            t.Errorf("%s not found in the gathered metrics", tt.name)
+        })
+    }
+}
+{{- if .HasStateSet}}
+
+func TestGeneratedStateSetsIgnoreUnknownStates(t *testing.T) {
+    {{- range .Metrics}}
+    {{- if eq .Type "stateset"}}
+    t.Run("{{fullName .}}", func(t *testing.T) {
+        reg := {{pkg "prometheus"}}.NewPedanticRegistry()
+        {{- if eq $.API "struct"}}
+        m, err := NewMetrics(reg)
+        if err != nil {
+            t.Fatalf("NewMetrics: %v", err)
+        }
+        {{- else}}
+        if err := reg.Register({{snakeToCamel .Name}}); err != nil {
+            t.Fatalf("registering {{fullName .}}: %v", err)
+        }
+        {{- end}}
+
+        {{if eq $.API "struct"}}m.{{end}}Set{{snakeToCamel .Name}}({{template "testLabelValues" .}}{{printf "%q" (index .States 0)}})
+        {{if eq $.API "struct"}}m.{{end}}Set{{snakeToCamel .Name}}({{template "testLabelValues" .}}"promc_unknown_state")
+
+        families, err := reg.Gather()
+        if err != nil {
+            t.Fatalf("gathering {{fullName .}}: %v", err)
+        }
+        active := 0
+        for _, family := range families {
+            if family.GetName() != "{{fullName .}}" {
+                continue
+            }
+            for _, metric := range family.GetMetric() {
+                if metric.GetGauge().GetValue() != 1 {
+                    continue
+                }
+                active++
+                for _, label := range metric.GetLabel() {
+                    if label.GetName() == "{{fullName .}}" && label.GetValue() != {{printf "%q" (index .States 0)}} {
+                        t.Errorf("state %s is active after setting an unknown state", label.GetValue())
+                    }
+                }
+            }
+        }
+        if active != 1 {
+            t.Errorf("%d active states after setting an unknown state, want 1", active)
+        }
+    })
+    {{- end}}
+    {{- end}}
+}
+{{- end}}
+
+{{- if eq .API "struct"}}
+
+func TestNewMetricsUnregistersOnError(t *testing.T) {
+    for fail := 1; ; fail++ {
+        reg := &failingRegisterer{Registerer: {{pkg "prometheus"}}.NewPedanticRegistry(), fail: fail}
+        if _, err := NewMetrics(reg); err == nil {
+            return
+        }
+        if reg.registered != 0 {
+            t.Errorf("%d collector(s) left registered when registration %d failed", reg.registered, fail)
+        }
+    }
+}
+
+// failingRegisterer fails the fail-th call to Register, and counts the
+// collectors that are registered with the wrapped Registerer.
+type failingRegisterer struct {
+    {{pkg "prometheus"}}.Registerer
+    fail       int
+    calls      int
+    registered int
+}
+
+func (r *failingRegisterer) Register(c {{pkg "prometheus"}}.Collector) error {
+    r.calls++
+    if r.calls == r.fail {
+        return {{pkg "errors"}}.New("registration failed")
+    }
+    if err := r.Registerer.Register(c); err != nil {
+        return err
+    }
+    r.registered++
+    return nil
+}
+
+func (r *failingRegisterer) Unregister(c {{pkg "prometheus"}}.Collector) bool {
+    if !r.Registerer.Unregister(c) {
+        return false
+    }
+    r.registered--
+    return true
+}
+{{- end}}
+
+{{define "testLabelValues" -}}
+    {{- range .Labels}}{{if .AllowedValues}}{{printf "%q" (index .AllowedValues 0)}}{{else}}"test"{{end}}, {{end}}
+{{- end}}
+`